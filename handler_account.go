@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/google/uuid"
+)
+
+// accountDeletionReauthWindow is how recently a user without a usable
+// password must have signed in to delete their account.
+const accountDeletionReauthWindow = 5 * time.Minute
+
+// handlerDeleteAccount deletes the caller's account once they confirm it with
+// their password or, for accounts linked to an identity provider, a recent
+// sign-in.
+func (cfg *apiConfig) handlerDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	params := struct {
+		Password string `json:"password"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(400)
+		return
+	}
+
+	user, err := cfg.Db.GetUser(r.Context(), claims.UserID)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+	if params.Password != "" {
+		if auth.CheckPasswordHash(params.Password, user.HashedPassword) != nil {
+			w.WriteHeader(403)
+			return
+		}
+	} else {
+		// Accounts created through single sign-on have a password nobody
+		// knows. Signing in again through the identity provider confirms
+		// the deletion instead.
+		identities, err := cfg.Db.GetUserIdentitiesForUser(r.Context(), user.ID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		signedInRecently := claims.AuthTime != nil && time.Since(claims.AuthTime.Time) <= accountDeletionReauthWindow
+		if len(identities) == 0 || !signedInRecently {
+			w.WriteHeader(403)
+			return
+		}
+	}
+
+	// Every access token the user still holds, not just this one, must stop
+	// working; none can outlive the longest access token lifetime. This
+	// comes first so that the account is never gone while its tokens work.
+	now := time.Now()
+	if err := cfg.Auth.Denylist.AddUser(r.Context(), user.ID, now, now.Add(cfg.AccessTokenTTL+cfg.Auth.Options.Leeway)); err != nil {
+		w.WriteHeader(500)
+		fmt.Println("denylist error", err)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	// Chirps, refresh tokens and everything else owned by the user go with
+	// it through ON DELETE CASCADE, and other users' plain rechirps of the
+	// chirps go with those. Each chirp is announced once the deletion
+	// commits.
+	deleted, err := qtx.GetChirpsDeletedWithUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("delete user error", err)
+		return
+	}
+	if err := qtx.DeleteUser(r.Context(), user.ID); err != nil {
+		w.WriteHeader(500)
+		fmt.Println("delete user error", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	for _, chirp := range deleted {
+		cfg.chirpDeleted(r.Context(), chirp)
+	}
+	w.WriteHeader(204)
+}
+
+type exportRecord struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type exportProfile struct {
+	Id          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+}
+
+type exportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type exportLike struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportFollow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type exportNotification struct {
+	Id        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+type exportIdentity struct {
+	CreatedAt time.Time `json:"created_at"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+}
+
+// handlerExportAccount streams everything Chirpy holds about the caller as
+// newline-delimited JSON, one typed record per line. Secrets such as the
+// password hash and refresh token values are left out.
+func (cfg *apiConfig) handlerExportAccount(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	user, err := cfg.Db.GetUser(r.Context(), claims.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	chirps, err := cfg.Db.GetChirpsForUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	sessions, err := cfg.Db.GetRefreshTokensForUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	personalTokens, err := cfg.Db.GetPersonalAccessTokensForUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	identities, err := cfg.Db.GetUserIdentitiesForUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	oauthClients, err := cfg.Db.GetOAuthClientsForUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	likes, err := cfg.Db.GetLikesByUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	follows, err := cfg.Db.GetFollowsInvolvingUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	notifications, err := cfg.Db.GetAllNotificationsForUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	prefs, err := cfg.notificationPreferences(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	userWebhooks, err := cfg.Db.GetWebhooksForUser(r.Context(), user.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirpy-export.ndjson"`)
+	w.WriteHeader(200)
+
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	write := func(recordType string, data any) bool {
+		if err := encoder.Encode(exportRecord{Type: recordType, Data: data}); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	profile := exportProfile{
+		Id:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		Role:        user.Role,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarUrl,
+	}
+	if !write("profile", profile) {
+		return
+	}
+	for _, chirp := range chirps {
+		res := ChirpRes{Id: chirp.ID, Created_at: chirp.CreatedAt, Updated_at: chirp.UpdatedAt, Body: chirp.Body, User_id: chirp.UserID}
+		if !write("chirp", res) {
+			return
+		}
+	}
+	for _, session := range sessions {
+		record := exportSession{CreatedAt: session.CreatedAt, ExpiresAt: session.ExpiresAt}
+		if session.RevokedAt.Valid {
+			record.RevokedAt = &session.RevokedAt.Time
+		}
+		if !write("session", record) {
+			return
+		}
+	}
+	for _, pat := range personalTokens {
+		if !write("personal_token", personalTokenRes(pat)) {
+			return
+		}
+	}
+	for _, identity := range identities {
+		record := exportIdentity{CreatedAt: identity.CreatedAt, Issuer: identity.Issuer, Subject: identity.Subject, Email: identity.Email}
+		if !write("identity", record) {
+			return
+		}
+	}
+	for _, client := range oauthClients {
+		if !write("oauth_client", oauthClientRes(client)) {
+			return
+		}
+	}
+	for _, like := range likes {
+		if !write("like", exportLike{ChirpID: like.ChirpID, CreatedAt: like.CreatedAt}) {
+			return
+		}
+	}
+	// Both directions: who the user follows and who follows them.
+	for _, follow := range follows {
+		if !write("follow", exportFollow{FollowerID: follow.FollowerID, FolloweeID: follow.FolloweeID, CreatedAt: follow.CreatedAt}) {
+			return
+		}
+	}
+	for _, notification := range notifications {
+		record := exportNotification{Id: notification.ID, CreatedAt: notification.CreatedAt, Type: notification.Type, ActorID: notification.ActorID}
+		if notification.ChirpID.Valid {
+			record.ChirpID = &notification.ChirpID.UUID
+		}
+		if notification.ReadAt.Valid {
+			record.ReadAt = &notification.ReadAt.Time
+		}
+		if !write("notification", record) {
+			return
+		}
+	}
+	if !write("notification_preferences", NotificationPreferencesRes{Mentions: prefs.Mentions, Replies: prefs.Replies, Likes: prefs.Likes, Follows: prefs.Follows}) {
+		return
+	}
+	// Signing secrets stay out, like every other secret.
+	for _, webhook := range userWebhooks {
+		if !write("webhook", webhookRes(webhook)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,692 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/pagination"
+	"github.com/BradDeA/chirpy.git/internal/stream"
+	"github.com/BradDeA/chirpy.git/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// defaultChirpRetention is how long a deleted chirp can still be restored
+// before the purger removes it for good.
+const defaultChirpRetention = 30 * 24 * time.Hour
+
+const maxChirpLength = 140
+
+var profaneWords = []string{"kerfuffle", "sharbert", "fornax"}
+
+// cleanChirpBody replaces each profane word in body with asterisks.
+func cleanChirpBody(body string) string {
+	words := strings.Split(body, " ")
+	for i, word := range words {
+		lowerWord := strings.ToLower(word)
+		for _, profaneWord := range profaneWords {
+			if lowerWord == profaneWord {
+				words[i] = "****"
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func writeChirpTooLong(w http.ResponseWriter) {
+	data, err := json.Marshal(ChirpRes{Body: "Chirp is too long"})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+	w.Write(data)
+}
+
+func chirpRes(chirp database.Chirp) ChirpRes {
+	res := ChirpRes{
+		Id:         chirp.ID,
+		Created_at: chirp.CreatedAt,
+		Updated_at: chirp.UpdatedAt,
+		Body:       chirp.Body,
+		User_id:    chirp.UserID,
+	}
+	if chirp.ReplyToID.Valid {
+		res.Reply_to_id = &chirp.ReplyToID.UUID
+	}
+	if chirp.RepostOfID.Valid {
+		res.Repost_of_id = &chirp.RepostOfID.UUID
+	}
+	return res
+}
+
+// chirpResponses builds the API representation of chirps, loading the
+// per-chirp counts for the whole batch in one query each. When the request
+// is authenticated, liked_by_me is filled in for the caller. Rechirps and
+// quote chirps embed the chirp they share, one level deep.
+func (cfg *apiConfig) chirpResponses(ctx context.Context, chirps []database.Chirp) ([]ChirpRes, error) {
+	res, err := cfg.countedChirpResponses(ctx, chirps)
+	if err != nil {
+		return nil, err
+	}
+
+	originalIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.RepostOfID.Valid {
+			originalIDs = append(originalIDs, chirp.RepostOfID.UUID)
+		}
+	}
+	if len(originalIDs) == 0 {
+		return res, nil
+	}
+
+	originals, err := cfg.Db.GetChirpsByIDs(ctx, originalIDs)
+	if err != nil {
+		return nil, err
+	}
+	embedded, err := cfg.countedChirpResponses(ctx, originals)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[uuid.UUID]*ChirpRes{}
+	for i := range embedded {
+		byID[embedded[i].Id] = &embedded[i]
+	}
+	for i, chirp := range chirps {
+		if chirp.RepostOfID.Valid {
+			res[i].Repost_of = byID[chirp.RepostOfID.UUID]
+		}
+	}
+	return res, nil
+}
+
+func (cfg *apiConfig) countedChirpResponses(ctx context.Context, chirps []database.Chirp) ([]ChirpRes, error) {
+	res := []ChirpRes{}
+	if len(chirps) == 0 {
+		return res, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+
+	counts, err := cfg.Db.CountRepliesForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	replyCounts := map[uuid.UUID]int64{}
+	for _, count := range counts {
+		replyCounts[count.ReplyToID.UUID] = count.ReplyCount
+	}
+
+	likes, err := cfg.Db.CountLikesForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	likeCounts := map[uuid.UUID]int64{}
+	for _, count := range likes {
+		likeCounts[count.ChirpID] = count.LikeCount
+	}
+
+	authorIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		authorIDs = append(authorIDs, chirp.UserID)
+	}
+	authorRows, err := cfg.Db.GetAuthorsByIDs(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	authors := map[uuid.UUID]database.GetAuthorsByIDsRow{}
+	for _, author := range authorRows {
+		authors[author.ID] = author
+	}
+
+	chirpEntities, err := cfg.entityResponses(ctx, chirps, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var liked map[uuid.UUID]bool
+	if claims, ok := auth.UserFromContext(ctx); ok {
+		likedIDs, err := cfg.Db.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{UserID: claims.UserID, ChirpIds: ids})
+		if err != nil {
+			return nil, err
+		}
+		liked = map[uuid.UUID]bool{}
+		for _, id := range likedIDs {
+			liked[id] = true
+		}
+	}
+
+	for _, chirp := range chirps {
+		entry := chirpRes(chirp)
+		entry.Reply_count = replyCounts[chirp.ID]
+		entry.Like_count = likeCounts[chirp.ID]
+		entry.Author_username = authors[chirp.UserID].Username
+		entry.Author_display_name = authors[chirp.UserID].DisplayName
+		entry.Entities = chirpEntities[chirp.ID]
+		if liked != nil {
+			likedByMe := liked[chirp.ID]
+			entry.Liked_by_me = &likedByMe
+		}
+		res = append(res, entry)
+	}
+	return res, nil
+}
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	chirp, err := cfg.Db.GetChirp(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	// Liking twice is a no-op, so PUT can be retried safely.
+	if err := cfg.Db.LikeChirp(r.Context(), database.LikeChirpParams{UserID: claims.UserID, ChirpID: chirp.ID}); err != nil {
+		w.WriteHeader(500)
+		fmt.Println("like chirp error", err)
+		return
+	}
+	if err := notify(r.Context(), cfg.Db, notificationLike, claims.UserID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, chirp.UserID); err != nil {
+		fmt.Println("like notification error", err)
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	if err := cfg.Db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{UserID: claims.UserID, ChirpID: chirpID}); err != nil {
+		w.WriteHeader(500)
+		fmt.Println("unlike chirp error", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerChirpLikes(w http.ResponseWriter, r *http.Request) {
+	type LikeRes struct {
+		UserID    uuid.UUID `json:"user_id"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	type LikesRes struct {
+		Likes      []LikeRes `json:"likes"`
+		NextCursor string    `json:"next_cursor,omitempty"`
+	}
+
+	chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+	page, pageErr := pagination.Parse(r.URL.Query())
+	if pageErr != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	_, err := cfg.Db.GetChirp(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	params := database.GetChirpLikesParams{ChirpID: chirpID, MaxResults: int32(page.Limit)}
+	if page.After != nil {
+		params.AfterCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.AfterUserID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	likes, err := cfg.Db.GetChirpLikes(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := LikesRes{Likes: []LikeRes{}}
+	for _, like := range likes {
+		res.Likes = append(res.Likes, LikeRes{UserID: like.UserID, CreatedAt: like.CreatedAt})
+	}
+	if len(likes) > 0 {
+		last := likes[len(likes)-1]
+		res.NextCursor = page.Next(len(likes), pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.UserID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
+// chirpCreated hands a newly stored chirp to the background consumers that
+// react to new chirps.
+func (cfg *apiConfig) chirpCreated(ctx context.Context, chirp database.Chirp) {
+	if cfg.Timeline != nil {
+		cfg.Timeline.Enqueue(chirp.ID)
+	}
+	cfg.publishChirpEvent(ctx, stream.EventChirpCreated, chirp.UserID, chirpRes(chirp))
+	cfg.enqueueWebhooks(ctx, webhooks.EventChirpCreated, chirp.UserID, chirpRes(chirp))
+}
+
+// chirpDeleted tells the same consumers a chirp is gone.
+func (cfg *apiConfig) chirpDeleted(ctx context.Context, chirp database.Chirp) {
+	deleted := struct {
+		Id      uuid.UUID `json:"id"`
+		User_id uuid.UUID `json:"user_id"`
+	}{chirp.ID, chirp.UserID}
+	cfg.publishChirpEvent(ctx, stream.EventChirpDeleted, chirp.UserID, deleted)
+	cfg.enqueueWebhooks(ctx, webhooks.EventChirpDeleted, chirp.UserID, deleted)
+}
+
+// handlerEditChirp lets the author change a chirp's body. The previous body
+// is kept in chirp_revisions.
+func (cfg *apiConfig) handlerEditChirp(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	params := struct {
+		Body string `json:"body"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	if len(params.Body) > maxChirpLength {
+		writeChirpTooLong(w)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	// Lock the row so concurrent edits each record the body they replaced.
+	chirp, err := qtx.GetChirpForUpdate(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if chirp.UserID != claims.UserID {
+		w.WriteHeader(403)
+		return
+	}
+	// A plain rechirp has no text of its own to edit, and a quote chirp
+	// cannot be emptied into one.
+	if chirp.RepostOfID.Valid && (chirp.Body == "" || params.Body == "") {
+		w.WriteHeader(400)
+		return
+	}
+
+	err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{ChirpID: chirp.ID, Body: chirp.Body})
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("create chirp revision error", err)
+		return
+	}
+	updated, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{ID: chirp.ID, Body: cleanChirpBody(params.Body)})
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("update chirp error", err)
+		return
+	}
+	if err := replaceChirpEntities(r.Context(), qtx, updated); err != nil {
+		w.WriteHeader(500)
+		fmt.Println("chirp entities error", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res, err := cfg.chirpResponses(r.Context(), []database.Chirp{updated})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res[0])
+}
+
+// handlerRechirp shares a chirp with the caller's followers. Without a body
+// it creates a plain rechirp, which a user can make only once per chirp; with
+// a body it creates a quote chirp under the usual length and profanity rules.
+func (cfg *apiConfig) handlerRechirp(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	params := struct {
+		Body string `json:"body"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err != io.EOF {
+		w.WriteHeader(400)
+		return
+	}
+	if len(params.Body) > maxChirpLength {
+		writeChirpTooLong(w)
+		return
+	}
+
+	original, err := cfg.Db.GetChirp(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	// Rechirping a plain rechirp shares the chirp it points at.
+	if original.Body == "" && original.RepostOfID.Valid {
+		original, err = cfg.Db.GetChirp(r.Context(), original.RepostOfID.UUID)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(404)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+	}
+	repostOf := uuid.NullUUID{UUID: original.ID, Valid: true}
+
+	var chirp database.Chirp
+	if params.Body == "" {
+		chirp, err = cfg.Db.CreateRechirp(r.Context(), database.CreateRechirpParams{UserID: claims.UserID, RepostOfID: repostOf})
+		if err == sql.ErrNoRows {
+			w.WriteHeader(409)
+			return
+		}
+	} else {
+		chirp, err = cfg.createChirp(r.Context(), database.CreateChirpParams{
+			Body:       cleanChirpBody(params.Body),
+			UserID:     claims.UserID,
+			RepostOfID: repostOf,
+		})
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("rechirp error", err)
+		return
+	}
+	cfg.chirpCreated(r.Context(), chirp)
+
+	res, err := cfg.chirpResponses(r.Context(), []database.Chirp{chirp})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(res[0])
+}
+
+// handlerChirpThread returns a chirp together with the chain of chirps it
+// replies to, root first, and a page of every reply beneath it in creation
+// order. Each reply carries reply_to_id so clients can rebuild the tree.
+func (cfg *apiConfig) handlerChirpThread(w http.ResponseWriter, r *http.Request) {
+	type ThreadRes struct {
+		Ancestors  []ChirpRes `json:"ancestors"`
+		Chirp      ChirpRes   `json:"chirp"`
+		Replies    []ChirpRes `json:"replies"`
+		NextCursor string     `json:"next_cursor,omitempty"`
+	}
+
+	chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+	page, pageErr := pagination.Parse(r.URL.Query())
+	if pageErr != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	chirp, err := cfg.Db.GetChirp(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	ancestors, err := cfg.Db.GetChirpAncestors(r.Context(), chirp.ID)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("chirp ancestors error", err)
+		return
+	}
+
+	params := database.GetChirpRepliesParams{ChirpID: chirp.ID, MaxResults: int32(page.Limit)}
+	if page.After != nil {
+		params.AfterCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	replies, err := cfg.Db.GetChirpReplies(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("chirp replies error", err)
+		return
+	}
+
+	thread := make([]database.Chirp, 0, len(ancestors)+1+len(replies))
+	thread = append(thread, ancestors...)
+	thread = append(thread, chirp)
+	thread = append(thread, replies...)
+	enriched, err := cfg.chirpResponses(r.Context(), thread)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := ThreadRes{
+		Ancestors: enriched[:len(ancestors)],
+		Chirp:     enriched[len(ancestors)],
+		Replies:   enriched[len(ancestors)+1:],
+	}
+	if len(replies) > 0 {
+		last := replies[len(replies)-1]
+		res.NextCursor = page.Next(len(replies), pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (cfg *apiConfig) handlerChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	type RevisionRes struct {
+		Id        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		Body      string    `json:"body"`
+	}
+
+	chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	_, err := cfg.Db.GetChirp(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	revisions, err := cfg.Db.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []RevisionRes{}
+	for _, revision := range revisions {
+		res = append(res, RevisionRes{Id: revision.ID, CreatedAt: revision.CreatedAt, Body: revision.Body})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (cfg *apiConfig) handlerRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	chirp, err := cfg.Db.GetDeletedChirp(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if time.Since(chirp.DeletedAt.Time) > cfg.ChirpRetention {
+		w.WriteHeader(410)
+		return
+	}
+
+	// A moderator's deletion is recorded in the same transaction, so at the
+	// same NOW(), as deleted_at. Only a moderator can undo it.
+	moderated, err := cfg.Db.IsChirpDeletionModerated(r.Context(), database.IsChirpDeletionModeratedParams{ChirpID: chirp.ID, DeletedAt: chirp.DeletedAt.Time})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	// A plain rechirp comes back with its original, not before it.
+	if chirp.Body == "" && chirp.RepostOfID.Valid {
+		_, err = cfg.Db.GetChirp(r.Context(), chirp.RepostOfID.UUID)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(409)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+	}
+
+	// Plain rechirps deleted along with the chirp are restored with it.
+	var restored []database.Chirp
+	var restoreErr error
+	if chirp.UserID == claims.UserID && !moderated {
+		restored, restoreErr = cfg.Db.RestoreChirp(r.Context(), chirp.ID)
+	} else if claims.HasRole(auth.RoleModerator) {
+		restored, restoreErr = cfg.moderateChirp(r.Context(), claims.UserID, moderationRestoreChirp, chirp)
+	} else {
+		w.WriteHeader(403)
+		return
+	}
+	// The user has rechirped the same chirp again since deleting this one.
+	if isUniqueViolation(restoreErr) {
+		w.WriteHeader(409)
+		return
+	}
+	if restoreErr != nil {
+		w.WriteHeader(500)
+		fmt.Println("restore chirp error", restoreErr)
+		return
+	}
+	for _, restoredChirp := range restored {
+		cfg.chirpCreated(r.Context(), restoredChirp)
+	}
+
+	w.WriteHeader(204)
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a write that
+// would break a unique index.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// runChirpPurger permanently removes chirps that have been soft-deleted for
+// longer than retention. They were announced as deleted when they were soft
+// deleted, so nothing is announced again. It blocks until ctx is cancelled.
+func runChirpPurger(ctx context.Context, db *database.Queries, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := db.PurgeDeletedChirps(ctx, time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("purging deleted chirps: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("purged %d deleted chirps", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/entities"
+	"github.com/BradDeA/chirpy.git/internal/pagination"
+	"github.com/google/uuid"
+)
+
+// EntityRes is a parsed @mention or #hashtag in a chirp body. Mentions of
+// existing users carry the mentioned user's id.
+type EntityRes struct {
+	Type   string     `json:"type"`
+	Text   string     `json:"text"`
+	Start  int        `json:"start"`
+	End    int        `json:"end"`
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+}
+
+// createChirp stores a new chirp together with the mentions and hashtags
+// parsed from its body, and tells the author of the chirp it replies to.
+func (cfg *apiConfig) createChirp(ctx context.Context, params database.CreateChirpParams) (database.Chirp, error) {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.Db.WithTx(tx)
+	chirp, err := qtx.CreateChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if err := recordChirpEntities(ctx, qtx, chirp); err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.ReplyToID.Valid {
+		parent, err := qtx.GetChirp(ctx, chirp.ReplyToID.UUID)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		if err := notify(ctx, qtx, notificationReply, chirp.UserID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, parent.UserID); err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	return chirp, tx.Commit()
+}
+
+// recordChirpEntities indexes the mentions and hashtags in chirp's body and
+// notifies the mentioned users. Mentions of handles that don't belong to
+// anyone are not recorded.
+func recordChirpEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	found := entities.Parse(chirp.Body)
+
+	if handles := entities.Mentions(found); len(handles) > 0 {
+		users, err := q.GetUsersByUsernames(ctx, handles)
+		if err != nil {
+			return err
+		}
+		mentioned := []uuid.UUID{}
+		for _, user := range users {
+			mentioned = append(mentioned, user.ID)
+			err := q.CreateChirpMention(ctx, database.CreateChirpMentionParams{
+				ChirpID:   chirp.ID,
+				UserID:    user.ID,
+				Handle:    strings.ToLower(user.Username),
+				CreatedAt: chirp.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := notify(ctx, q, notificationMention, chirp.UserID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, mentioned...); err != nil {
+			return err
+		}
+	}
+
+	for _, tag := range entities.Hashtags(found) {
+		err := q.CreateChirpHashtag(ctx, database.CreateChirpHashtagParams{ChirpID: chirp.ID, Tag: tag, CreatedAt: chirp.CreatedAt})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceChirpEntities re-indexes an edited chirp.
+func replaceChirpEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.DeleteChirpMentions(ctx, chirp.ID); err != nil {
+		return err
+	}
+	if err := q.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+		return err
+	}
+	return recordChirpEntities(ctx, q, chirp)
+}
+
+// entityResponses parses each chirp body and attaches the recorded mention
+// targets, keyed by chirp id.
+func (cfg *apiConfig) entityResponses(ctx context.Context, chirps []database.Chirp, ids []uuid.UUID) (map[uuid.UUID][]EntityRes, error) {
+	mentions, err := cfg.Db.GetMentionsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	mentioned := map[uuid.UUID]map[string]uuid.UUID{}
+	for _, mention := range mentions {
+		if mentioned[mention.ChirpID] == nil {
+			mentioned[mention.ChirpID] = map[string]uuid.UUID{}
+		}
+		mentioned[mention.ChirpID][mention.Handle] = mention.UserID
+	}
+
+	res := map[uuid.UUID][]EntityRes{}
+	for _, chirp := range chirps {
+		list := []EntityRes{}
+		for _, entity := range entities.Parse(chirp.Body) {
+			entry := EntityRes{Type: entity.Type, Text: entity.Text, Start: entity.Start, End: entity.End}
+			if entity.Type == entities.TypeMention {
+				if userID, ok := mentioned[chirp.ID][strings.ToLower(entity.Text)]; ok {
+					entry.UserID = &userID
+				}
+			}
+			list = append(list, entry)
+		}
+		res[chirp.ID] = list
+	}
+	return res, nil
+}
+
+func (cfg *apiConfig) handlerHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	page, pageErr := pagination.Parse(r.URL.Query())
+	if pageErr != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	params := database.GetChirpsByHashtagParams{Tag: entities.NormalizeTag(r.PathValue("tag")), MaxResults: int32(page.Limit)}
+	if page.After != nil {
+		params.BeforeCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	chirps, err := cfg.Db.GetChirpsByHashtag(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("hashtag chirps error", err)
+		return
+	}
+
+	cfg.writeChirpPage(w, r, page, chirps)
+}
+
+func (cfg *apiConfig) handlerMyMentions(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	page, pageErr := pagination.Parse(r.URL.Query())
+	if pageErr != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	params := database.GetMentionedChirpsParams{UserID: claims.UserID, MaxResults: int32(page.Limit)}
+	if page.After != nil {
+		params.BeforeCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	chirps, err := cfg.Db.GetMentionedChirps(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("mentions error", err)
+		return
+	}
+
+	cfg.writeChirpPage(w, r, page, chirps)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/pagination"
+	"github.com/google/uuid"
+)
+
+type FollowRes struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type FollowListRes struct {
+	Users      []FollowRes `json:"users"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	userID, parseErr := uuid.Parse(r.PathValue("userID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+	if userID == claims.UserID {
+		w.WriteHeader(400)
+		return
+	}
+
+	_, lookupErr := cfg.Db.GetUser(r.Context(), userID)
+	if lookupErr == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if lookupErr != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	err := cfg.Db.FollowUser(r.Context(), database.FollowUserParams{FollowerID: claims.UserID, FolloweeID: userID})
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("follow error", err)
+		return
+	}
+	if err := notify(r.Context(), cfg.Db, notificationFollow, claims.UserID, uuid.NullUUID{}, userID); err != nil {
+		fmt.Println("follow notification error", err)
+	}
+	if cfg.Timeline != nil {
+		if err := cfg.Timeline.Follow(r.Context(), claims.UserID, userID); err != nil {
+			fmt.Println("timeline backfill error", err)
+		}
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	userID, parseErr := uuid.Parse(r.PathValue("userID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	err := cfg.Db.UnfollowUser(r.Context(), database.UnfollowUserParams{FollowerID: claims.UserID, FolloweeID: userID})
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("unfollow error", err)
+		return
+	}
+	if cfg.Timeline != nil {
+		if err := cfg.Timeline.Unfollow(r.Context(), claims.UserID, userID); err != nil {
+			fmt.Println("timeline cleanup error", err)
+		}
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerFollowers(w http.ResponseWriter, r *http.Request) {
+	cfg.writeFollowList(w, r, true)
+}
+
+func (cfg *apiConfig) handlerFollowing(w http.ResponseWriter, r *http.Request) {
+	cfg.writeFollowList(w, r, false)
+}
+
+// writeFollowList serves one page of the users following, or followed by,
+// the user in the path.
+func (cfg *apiConfig) writeFollowList(w http.ResponseWriter, r *http.Request, followers bool) {
+	userID, parseErr := uuid.Parse(r.PathValue("userID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+	page, pageErr := pagination.Parse(r.URL.Query())
+	if pageErr != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	_, lookupErr := cfg.Db.GetUser(r.Context(), userID)
+	if lookupErr == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if lookupErr != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	params := database.GetFollowersParams{UserID: userID, MaxResults: int32(page.Limit)}
+	if page.After != nil {
+		params.AfterCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+
+	var follows []database.Follow
+	var err error
+	other := func(f database.Follow) uuid.UUID { return f.FolloweeID }
+	if followers {
+		follows, err = cfg.Db.GetFollowers(r.Context(), params)
+		other = func(f database.Follow) uuid.UUID { return f.FollowerID }
+	} else {
+		follows, err = cfg.Db.GetFollowing(r.Context(), database.GetFollowingParams(params))
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := FollowListRes{Users: []FollowRes{}}
+	for _, follow := range follows {
+		res.Users = append(res.Users, FollowRes{UserID: other(follow), FollowedAt: follow.CreatedAt})
+	}
+	if len(follows) > 0 {
+		last := follows[len(follows)-1]
+		res.NextCursor = page.Next(len(follows), pagination.Cursor{CreatedAt: last.CreatedAt, ID: other(last)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/pagination"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationMention = "mention"
+	notificationReply   = "reply"
+	notificationLike    = "like"
+	notificationFollow  = "follow"
+)
+
+type NotificationRes struct {
+	Id               uuid.UUID  `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	Type             string     `json:"type"`
+	ActorID          uuid.UUID  `json:"actor_id"`
+	ActorUsername    string     `json:"actor_username"`
+	ActorDisplayName string     `json:"actor_display_name"`
+	ChirpID          *uuid.UUID `json:"chirp_id,omitempty"`
+	Read             bool       `json:"read"`
+	Cursor           string     `json:"cursor"`
+}
+
+type NotificationPreferencesRes struct {
+	Mentions bool `json:"mentions"`
+	Replies  bool `json:"replies"`
+	Likes    bool `json:"likes"`
+	Follows  bool `json:"follows"`
+}
+
+// notify records a notification of kind for each recipient. Recipients never
+// hear about their own actions, are skipped when they have turned kind off,
+// and are told about the same action on the same chirp only once.
+func notify(ctx context.Context, q *database.Queries, kind string, actorID uuid.UUID, chirpID uuid.NullUUID, recipients ...uuid.UUID) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	return q.CreateNotifications(ctx, database.CreateNotificationsParams{
+		ActorID: actorID,
+		Type:    kind,
+		ChirpID: chirpID,
+		UserIds: recipients,
+	})
+}
+
+// notificationPreferences returns the user's preferences, with everything
+// switched on for users who never changed them.
+func (cfg *apiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) (database.NotificationPreference, error) {
+	prefs, err := cfg.Db.GetNotificationPreferences(ctx, userID)
+	if err == sql.ErrNoRows {
+		return database.NotificationPreference{UserID: userID, Mentions: true, Replies: true, Likes: true, Follows: true}, nil
+	}
+	return prefs, err
+}
+
+func (cfg *apiConfig) handlerNotifications(w http.ResponseWriter, r *http.Request) {
+	type NotificationPageRes struct {
+		Notifications []NotificationRes `json:"notifications"`
+		UnreadCount   int64             `json:"unread_count"`
+		NextCursor    string            `json:"next_cursor,omitempty"`
+	}
+
+	claims, _ := auth.UserFromContext(r.Context())
+
+	page, pageErr := pagination.Parse(r.URL.Query())
+	if pageErr != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	params := database.GetNotificationsParams{UserID: claims.UserID, MaxResults: int32(page.Limit)}
+	if page.After != nil {
+		params.BeforeCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	notifications, err := cfg.Db.GetNotifications(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("notifications error", err)
+		return
+	}
+	unread, err := cfg.Db.CountUnreadNotifications(r.Context(), claims.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	actorIDs := []uuid.UUID{}
+	for _, notification := range notifications {
+		actorIDs = append(actorIDs, notification.ActorID)
+	}
+	actorRows, err := cfg.Db.GetAuthorsByIDs(r.Context(), actorIDs)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	actors := map[uuid.UUID]database.GetAuthorsByIDsRow{}
+	for _, actor := range actorRows {
+		actors[actor.ID] = actor
+	}
+
+	res := NotificationPageRes{Notifications: []NotificationRes{}, UnreadCount: unread}
+	for _, notification := range notifications {
+		cursor := pagination.Cursor{CreatedAt: notification.CreatedAt, ID: notification.ID}
+		entry := NotificationRes{
+			Id:               notification.ID,
+			CreatedAt:        notification.CreatedAt,
+			Type:             notification.Type,
+			ActorID:          notification.ActorID,
+			ActorUsername:    actors[notification.ActorID].Username,
+			ActorDisplayName: actors[notification.ActorID].DisplayName,
+			Read:             notification.ReadAt.Valid,
+			Cursor:           cursor.Encode(),
+		}
+		if notification.ChirpID.Valid {
+			entry.ChirpID = &notification.ChirpID.UUID
+		}
+		res.Notifications = append(res.Notifications, entry)
+	}
+	if len(notifications) > 0 {
+		last := notifications[len(notifications)-1]
+		res.NextCursor = page.Next(len(notifications), pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
+// handlerReadNotifications marks the caller's notifications read up to and
+// including the one the cursor points at, or all of them when no cursor is
+// given. Notifications that arrive later stay unread.
+func (cfg *apiConfig) handlerReadNotifications(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	params := struct {
+		Cursor string `json:"cursor"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err != io.EOF {
+		w.WriteHeader(400)
+		return
+	}
+
+	mark := database.MarkNotificationsReadParams{UserID: claims.UserID}
+	if params.Cursor != "" {
+		through, err := pagination.Decode(params.Cursor)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		mark.ThroughCreatedAt = sql.NullTime{Time: through.CreatedAt, Valid: true}
+		mark.ThroughID = uuid.NullUUID{UUID: through.ID, Valid: true}
+	}
+	if _, err := cfg.Db.MarkNotificationsRead(r.Context(), mark); err != nil {
+		w.WriteHeader(500)
+		fmt.Println("mark notifications read error", err)
+		return
+	}
+
+	unread, err := cfg.Db.CountUnreadNotifications(r.Context(), claims.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(struct {
+		UnreadCount int64 `json:"unread_count"`
+	}{unread})
+}
+
+func (cfg *apiConfig) handlerGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	prefs, err := cfg.notificationPreferences(r.Context(), claims.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(NotificationPreferencesRes{Mentions: prefs.Mentions, Replies: prefs.Replies, Likes: prefs.Likes, Follows: prefs.Follows})
+}
+
+// handlerUpdateNotificationPreferences switches individual notification kinds
+// on or off. Kinds left out of the request keep their current setting.
+func (cfg *apiConfig) handlerUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	params := struct {
+		Mentions *bool `json:"mentions"`
+		Replies  *bool `json:"replies"`
+		Likes    *bool `json:"likes"`
+		Follows  *bool `json:"follows"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), claims.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	update := database.UpsertNotificationPreferencesParams{
+		UserID:   claims.UserID,
+		Mentions: prefs.Mentions,
+		Replies:  prefs.Replies,
+		Likes:    prefs.Likes,
+		Follows:  prefs.Follows,
+	}
+	if params.Mentions != nil {
+		update.Mentions = *params.Mentions
+	}
+	if params.Replies != nil {
+		update.Replies = *params.Replies
+	}
+	if params.Likes != nil {
+		update.Likes = *params.Likes
+	}
+	if params.Follows != nil {
+		update.Follows = *params.Follows
+	}
+
+	prefs, err = cfg.Db.UpsertNotificationPreferences(r.Context(), update)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("notification preferences error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(NotificationPreferencesRes{Mentions: prefs.Mentions, Replies: prefs.Replies, Likes: prefs.Likes, Follows: prefs.Follows})
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/oauth"
+	"github.com/google/uuid"
+)
+
+type OAuthClientRes struct {
+	Id           string    `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	Secret       string    `json:"client_secret,omitempty"`
+}
+
+func oauthClientRes(client database.OauthClient) OAuthClientRes {
+	return OAuthClientRes{
+		Id:           client.ID,
+		CreatedAt:    client.CreatedAt,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.SecretHash.Valid,
+	}
+}
+
+func (cfg *apiConfig) handlerCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	params := struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil || params.Name == "" || len(params.RedirectURIs) == 0 || len(params.Scopes) == 0 {
+		w.WriteHeader(400)
+		return
+	}
+	for _, redirectURI := range params.RedirectURIs {
+		if !oauth.ValidRedirectURI(redirectURI) {
+			w.WriteHeader(400)
+			return
+		}
+	}
+	for _, scope := range params.Scopes {
+		if !auth.ValidScope(scope) {
+			w.WriteHeader(400)
+			return
+		}
+	}
+
+	clientID, idErr := oauth.NewClientID()
+	if idErr != nil {
+		w.WriteHeader(500)
+		return
+	}
+	var secret string
+	secretHash := sql.NullString{}
+	if params.Confidential {
+		secret, err = oauth.NewClientSecret()
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, createErr := cfg.Db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           clientID,
+		OwnerID:      claims.UserID,
+		Name:         params.Name,
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+		Scopes:       params.Scopes,
+	})
+	if createErr != nil {
+		w.WriteHeader(500)
+		fmt.Println("create oauth client error", createErr)
+		return
+	}
+
+	res := oauthClientRes(client)
+	res.Secret = secret
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (cfg *apiConfig) handlerListOAuthClients(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	clients, err := cfg.Db.GetOAuthClientsForUser(r.Context(), claims.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []OAuthClientRes{}
+	for _, client := range clients {
+		res = append(res, oauthClientRes(client))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (cfg *apiConfig) handlerDeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	deleted, err := cfg.Db.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{ID: r.PathValue("clientID"), OwnerID: claims.UserID})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scope         string
+	State         string
+	CodeChallenge string
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<html>
+  <body>
+    <h1>Authorize {{.Client.Name}}</h1>
+    <p>{{.Client.Name}} would like to access your Chirpy account with these permissions:</p>
+    <ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+    {{if .Failed}}<p>Invalid email or password.</p>{{end}}
+    <form method="POST" action="/oauth/authorize">
+      <input type="hidden" name="response_type" value="code">
+      <input type="hidden" name="client_id" value="{{.Client.ID}}">
+      <input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+      <input type="hidden" name="scope" value="{{.Scope}}">
+      <input type="hidden" name="state" value="{{.State}}">
+      <input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+      <input type="hidden" name="code_challenge_method" value="S256">
+      <label>Email <input type="email" name="email"></label>
+      <label>Password <input type="password" name="password"></label>
+      <button type="submit" name="decision" value="approve">Allow</button>
+      <button type="submit" name="decision" value="deny">Deny</button>
+    </form>
+  </body>
+</html>`))
+
+func (cfg *apiConfig) renderConsent(w http.ResponseWriter, req authorizeRequest, failed bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Cache-Control", "no-store")
+	if failed {
+		w.WriteHeader(401)
+	} else {
+		w.WriteHeader(200)
+	}
+	consentTemplate.Execute(w, struct {
+		authorizeRequest
+		Scopes []string
+		Failed bool
+	}{req, strings.Fields(req.Scope), failed})
+}
+
+// parseAuthorizeRequest validates an authorization request. A non-empty
+// redirect means the error may be reported to the client's redirect URI;
+// otherwise the client or redirect URI itself is untrustworthy and the error
+// must be shown to the user instead.
+func (cfg *apiConfig) parseAuthorizeRequest(r *http.Request, values url.Values) (authorizeRequest, string, error) {
+	client, err := cfg.Db.GetOAuthClient(r.Context(), values.Get("client_id"))
+	if err != nil {
+		return authorizeRequest{}, "", errors.New("unknown client")
+	}
+	redirectURI := values.Get("redirect_uri")
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return authorizeRequest{}, "", errors.New("redirect_uri is not registered for this client")
+	}
+
+	req := authorizeRequest{Client: client, RedirectURI: redirectURI, State: values.Get("state")}
+	errorRedirect := func(code, description string) string {
+		return oauth.RedirectURL(redirectURI, url.Values{"error": {code}, "error_description": {description}, "state": {req.State}})
+	}
+
+	if values.Get("response_type") != "code" {
+		return req, errorRedirect(oauth.ErrUnsupportedResponse, "only the code response type is supported"), errors.New("bad response_type")
+	}
+	if values.Get("code_challenge") == "" || values.Get("code_challenge_method") != "S256" {
+		return req, errorRedirect(oauth.ErrInvalidRequest, "PKCE with S256 is required"), errors.New("missing PKCE")
+	}
+	req.CodeChallenge = values.Get("code_challenge")
+
+	scope, scopeErr := oauth.NormalizeScope(values.Get("scope"), client.Scopes)
+	if scopeErr != nil {
+		return req, errorRedirect(oauth.ErrInvalidScope, scopeErr.Error()), scopeErr
+	}
+	req.Scope = scope
+	return req, "", nil
+}
+
+func (cfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, redirect, err := cfg.parseAuthorizeRequest(r, r.URL.Query())
+	if err != nil {
+		if redirect != "" {
+			http.Redirect(w, r, redirect, http.StatusFound)
+			return
+		}
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	cfg.renderConsent(w, req, false)
+}
+
+func (cfg *apiConfig) handlerOAuthAuthorizeSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	req, redirect, err := cfg.parseAuthorizeRequest(r, r.PostForm)
+	if err != nil {
+		if redirect != "" {
+			http.Redirect(w, r, redirect, http.StatusFound)
+			return
+		}
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if r.PostForm.Get("decision") != "approve" {
+		http.Redirect(w, r, oauth.RedirectURL(req.RedirectURI, url.Values{"error": {oauth.ErrAccessDenied}, "state": {req.State}}), http.StatusFound)
+		return
+	}
+
+	user, lookupErr := cfg.Db.EmailLookup(r.Context(), r.PostForm.Get("email"))
+	if lookupErr != nil || auth.CheckPasswordHash(r.PostForm.Get("password"), user.HashedPassword) != nil {
+		cfg.renderConsent(w, req, true)
+		return
+	}
+
+	code, codeErr := auth.MakeRefreshToken()
+	if codeErr != nil {
+		w.WriteHeader(500)
+		return
+	}
+	createErr := cfg.Db.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.RedirectURI,
+		Scope:         req.Scope,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauth.AuthorizationCodeTTL),
+	})
+	if createErr != nil {
+		http.Redirect(w, r, oauth.RedirectURL(req.RedirectURI, url.Values{"error": {oauth.ErrServerError}, "state": {req.State}}), http.StatusFound)
+		return
+	}
+
+	http.Redirect(w, r, oauth.RedirectURL(req.RedirectURI, url.Values{"code": {code}, "state": {req.State}}), http.StatusFound)
+}
+
+// authenticateClient checks the client credentials on a token, introspection
+// or revocation request. Public clients authenticate with their ID alone.
+func (cfg *apiConfig) authenticateClient(r *http.Request) (database.OauthClient, bool) {
+	clientID, clientSecret := oauth.ClientCredentials(r)
+	client, err := cfg.Db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, false
+	}
+	if client.SecretHash.Valid {
+		presented := auth.HashToken(clientSecret)
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, false
+		}
+	}
+	return client, true
+}
+
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauth.WriteError(w, 400, oauth.ErrInvalidRequest, "")
+		return
+	}
+	client, ok := cfg.authenticateClient(r)
+	if !ok {
+		oauth.WriteError(w, 401, oauth.ErrInvalidClient, "")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		cfg.grantAuthorizationCode(w, r, client)
+	case "refresh_token":
+		cfg.grantRefreshToken(w, r, client)
+	default:
+		oauth.WriteError(w, 400, oauth.ErrUnsupportedGrantType, "")
+	}
+}
+
+// grantAuthorizationCode exchanges a code for tokens. The code is only
+// consumed once the caller has shown it is the client the code was issued
+// to, so a different client cannot burn someone else's code.
+func (cfg *apiConfig) grantAuthorizationCode(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		oauth.WriteError(w, 500, oauth.ErrServerError, "")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	codeHash := auth.HashToken(r.PostForm.Get("code"))
+	code, err := qtx.GetOAuthAuthorizationCodeForUpdate(r.Context(), codeHash)
+	if err != nil {
+		oauth.WriteError(w, 400, oauth.ErrInvalidGrant, "authorization code is invalid or expired")
+		return
+	}
+	if code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+		oauth.WriteError(w, 400, oauth.ErrInvalidGrant, "authorization code was issued to another client or redirect_uri")
+		return
+	}
+	if !oauth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		oauth.WriteError(w, 400, oauth.ErrInvalidGrant, "code_verifier does not match")
+		return
+	}
+	if _, err := qtx.ConsumeOAuthAuthorizationCode(r.Context(), codeHash); err != nil {
+		oauth.WriteError(w, 500, oauth.ErrServerError, "")
+		return
+	}
+
+	res, err := cfg.issueOAuthTokens(r.Context(), qtx, client, code.UserID, code.Scope, uuid.New())
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		oauth.WriteError(w, 500, oauth.ErrServerError, "")
+		fmt.Println("issue oauth tokens error", err)
+		return
+	}
+	oauth.WriteJSON(w, res)
+}
+
+// grantRefreshToken rotates a refresh token. Presenting a token that has
+// already been rotated means it leaked, so the whole family is revoked.
+func (cfg *apiConfig) grantRefreshToken(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	tokenHash := auth.HashToken(r.PostForm.Get("refresh_token"))
+	current, err := cfg.Db.GetOAuthRefreshToken(r.Context(), tokenHash)
+	if err != nil || current.ClientID != client.ID {
+		oauth.WriteError(w, 400, oauth.ErrInvalidGrant, "refresh token is invalid")
+		return
+	}
+	if current.RevokedAt.Valid {
+		cfg.Db.RevokeOAuthRefreshTokenFamily(r.Context(), current.FamilyID)
+		oauth.WriteError(w, 400, oauth.ErrInvalidGrant, "refresh token has been revoked")
+		return
+	}
+	if !time.Now().Before(current.ExpiresAt) {
+		oauth.WriteError(w, 400, oauth.ErrInvalidGrant, "refresh token has expired")
+		return
+	}
+
+	scope := current.Scope
+	if requested := r.PostForm.Get("scope"); requested != "" {
+		narrowed, scopeErr := oauth.NormalizeScope(requested, strings.Fields(current.Scope))
+		if scopeErr != nil {
+			oauth.WriteError(w, 400, oauth.ErrInvalidScope, scopeErr.Error())
+			return
+		}
+		scope = narrowed
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		oauth.WriteError(w, 500, oauth.ErrServerError, "")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	rotated, err := qtx.RevokeOAuthRefreshToken(r.Context(), tokenHash)
+	if err != nil {
+		oauth.WriteError(w, 500, oauth.ErrServerError, "")
+		return
+	}
+	if rotated == 0 {
+		// Lost a race with another request presenting the same token.
+		tx.Rollback()
+		cfg.Db.RevokeOAuthRefreshTokenFamily(r.Context(), current.FamilyID)
+		oauth.WriteError(w, 400, oauth.ErrInvalidGrant, "refresh token has been revoked")
+		return
+	}
+
+	res, err := cfg.issueOAuthTokens(r.Context(), qtx, client, current.UserID, scope, current.FamilyID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		oauth.WriteError(w, 500, oauth.ErrServerError, "")
+		fmt.Println("issue oauth tokens error", err)
+		return
+	}
+	oauth.WriteJSON(w, res)
+}
+
+func (cfg *apiConfig) issueOAuthTokens(ctx context.Context, q *database.Queries, client database.OauthClient, userID uuid.UUID, scope string, familyID uuid.UUID) (oauth.TokenResponse, error) {
+	accessToken, err := auth.MakeJWT(userID, cfg.SecretKey, auth.TokenOptions{
+		TTL:      cfg.AccessTokenTTL,
+		Audience: cfg.Auth.Options.Audience,
+		Scope:    scope,
+		ClientID: client.ID,
+	})
+	if err != nil {
+		return oauth.TokenResponse{}, err
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return oauth.TokenResponse{}, err
+	}
+	_, err = q.CreateOAuthRefreshToken(ctx, database.CreateOAuthRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
+		ClientID:  client.ID,
+		UserID:    userID,
+		FamilyID:  familyID,
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(oauth.RefreshTokenTTL),
+	})
+	if err != nil {
+		return oauth.TokenResponse{}, err
+	}
+
+	return oauth.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(cfg.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	}, nil
+}
+
+// handlerOAuthIntrospect implements RFC 7662. Clients can only introspect
+// tokens that were issued to them; everything else reports inactive.
+func (cfg *apiConfig) handlerOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauth.WriteError(w, 400, oauth.ErrInvalidRequest, "")
+		return
+	}
+	client, ok := cfg.authenticateClient(r)
+	if !ok {
+		oauth.WriteError(w, 401, oauth.ErrInvalidClient, "")
+		return
+	}
+	token := r.PostForm.Get("token")
+
+	if claims, err := auth.ValidateJWT(token, cfg.SecretKey, cfg.Auth.Options); err == nil {
+		// A token that cannot be checked against the denylist may have
+		// been revoked, so it is not reported active.
+		revoked, revokedErr := cfg.Auth.Revoked(r.Context(), claims)
+		if revokedErr != nil {
+			fmt.Println("introspect denylist error", revokedErr)
+		}
+		if revoked || revokedErr != nil || claims.ClientID != client.ID {
+			oauth.WriteJSON(w, oauth.IntrospectionResponse{Active: false})
+			return
+		}
+		oauth.WriteJSON(w, oauth.IntrospectionResponse{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			Subject:   claims.Subject,
+			TokenType: "access_token",
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			IssuedAt:  claims.IssuedAt.Unix(),
+		})
+		return
+	}
+
+	refresh, err := cfg.Db.GetOAuthRefreshToken(r.Context(), auth.HashToken(token))
+	if err != nil || refresh.ClientID != client.ID || refresh.RevokedAt.Valid || !time.Now().Before(refresh.ExpiresAt) {
+		oauth.WriteJSON(w, oauth.IntrospectionResponse{Active: false})
+		return
+	}
+	oauth.WriteJSON(w, oauth.IntrospectionResponse{
+		Active:    true,
+		Scope:     refresh.Scope,
+		ClientID:  refresh.ClientID,
+		Subject:   refresh.UserID.String(),
+		TokenType: "refresh_token",
+		ExpiresAt: refresh.ExpiresAt.Unix(),
+		IssuedAt:  refresh.CreatedAt.Unix(),
+	})
+}
+
+// handlerOAuthRevoke implements RFC 7009. Revoking a refresh token revokes
+// its whole rotation family; revoking an access token denylists it. Unknown
+// tokens are not an error.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauth.WriteError(w, 400, oauth.ErrInvalidRequest, "")
+		return
+	}
+	client, ok := cfg.authenticateClient(r)
+	if !ok {
+		oauth.WriteError(w, 401, oauth.ErrInvalidClient, "")
+		return
+	}
+	token := r.PostForm.Get("token")
+
+	if claims, err := auth.ValidateJWT(token, cfg.SecretKey, cfg.Auth.Options); err == nil {
+		if claims.ClientID == client.ID {
+			if denyErr := cfg.Auth.Denylist.Add(r.Context(), claims.ID, claims.ExpiresAt.Time); denyErr != nil {
+				oauth.WriteError(w, 503, oauth.ErrServerError, "")
+				return
+			}
+		}
+		w.WriteHeader(200)
+		return
+	}
+
+	refresh, err := cfg.Db.GetOAuthRefreshToken(r.Context(), auth.HashToken(token))
+	if err == nil && refresh.ClientID == client.ID {
+		if revokeErr := cfg.Db.RevokeOAuthRefreshTokenFamily(r.Context(), refresh.FamilyID); revokeErr != nil {
+			oauth.WriteError(w, 503, oauth.ErrServerError, "")
+			return
+		}
+	}
+	w.WriteHeader(200)
+}
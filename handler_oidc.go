@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/oidc"
+)
+
+const oidcStateCookie = "chirpy_oidc_state"
+
+func (cfg *apiConfig) handlerOIDCStart(w http.ResponseWriter, r *http.Request) {
+	state, challenge, err := oidc.NewLoginState()
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	sealed, err := oidc.SealLoginState(state, cfg.SecretKey)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    sealed,
+		Path:     "/api/auth/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, cfg.Identity.AuthCodeURL(state.State, state.Nonce, challenge), http.StatusFound)
+}
+
+func (cfg *apiConfig) handlerOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/auth/oidc", MaxAge: -1})
+
+	state, err := oidc.OpenLoginState(cookie.Value, cfg.SecretKey)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		w.WriteHeader(400)
+		return
+	}
+	if query.Get("error") != "" || query.Get("code") == "" {
+		w.WriteHeader(401)
+		return
+	}
+
+	identity, err := cfg.Identity.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		w.WriteHeader(401)
+		fmt.Println("oidc exchange error", err)
+		return
+	}
+
+	user, err := cfg.userForIdentity(r.Context(), identity)
+	if errors.Is(err, errUnverifiedIdentity) {
+		w.WriteHeader(403)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("oidc user error", err)
+		return
+	}
+
+	marshalValues, sessionErr := cfg.createSession(r.Context(), user)
+	if sessionErr != nil {
+		w.WriteHeader(500)
+		fmt.Println("create session error", sessionErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(marshalValues)
+}
+
+var errUnverifiedIdentity = errors.New("identity provider did not supply a verified email")
+
+// userForIdentity returns the Chirpy user linked to an external identity. The
+// first time an identity is seen it is linked to the account with the same
+// email, or to a new account when there is none. Linking by email is only
+// safe when the provider has verified that address.
+func (cfg *apiConfig) userForIdentity(ctx context.Context, identity oidc.Identity) (database.User, error) {
+	user, err := cfg.Db.GetUserByIdentity(ctx, database.GetUserByIdentityParams{Issuer: identity.Issuer, Subject: identity.Subject})
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return user, err
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		return database.User{}, errUnverifiedIdentity
+	}
+
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.Db.WithTx(tx)
+
+	created := false
+	user, err = qtx.EmailLookup(ctx, identity.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		// SSO-only accounts get a random password nobody knows.
+		password, tokenErr := auth.MakeRefreshToken()
+		if tokenErr != nil {
+			return database.User{}, tokenErr
+		}
+		hash, hashErr := auth.HashPassword(password)
+		if hashErr != nil {
+			return database.User{}, hashErr
+		}
+		username, nameErr := generateUsername()
+		if nameErr != nil {
+			return database.User{}, nameErr
+		}
+		user, err = qtx.CreateUser(ctx, database.CreateUserParams{Email: identity.Email, HashedPassword: hash, Username: username})
+		created = err == nil
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	_, err = qtx.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		UserID:  user.ID,
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		Email:   identity.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return database.User{}, err
+	}
+	if created {
+		cfg.userCreated(ctx, user)
+	}
+	return user, nil
+}
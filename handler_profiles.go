@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,15}$`)
+
+// ProfileRes is the public view of a user. It must never carry the email.
+type ProfileRes struct {
+	Id          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+}
+
+func profileRes(user database.User) ProfileRes {
+	return ProfileRes{
+		Id:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarUrl,
+	}
+}
+
+// userValues is the account owner's own view of user, without tokens.
+func userValues(user database.User) UserValues {
+	return UserValues{
+		Id:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		Role:        user.Role,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarUrl,
+	}
+}
+
+// generateUsername picks a placeholder handle for accounts created without
+// one.
+func generateUsername() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "user_" + hex.EncodeToString(buf), nil
+}
+
+// usernameTaken reports whether a handle already belongs to an account other
+// than userID. Handles are compared case-insensitively.
+func (cfg *apiConfig) usernameTaken(r *http.Request, username string, userID uuid.UUID) (bool, error) {
+	existing, err := cfg.Db.GetUserByUsername(r.Context(), username)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return existing.ID != userID, nil
+}
+
+func validAvatarURL(avatarURL string) bool {
+	if avatarURL == "" {
+		return true
+	}
+	if len(avatarURL) > maxAvatarURLLength {
+		return false
+	}
+	parsed, err := url.Parse(avatarURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func (cfg *apiConfig) handlerGetProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.Db.GetUserByUsername(r.Context(), r.PathValue("username"))
+	if err == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(profileRes(user))
+}
+
+// handlerUpdateProfile applies a partial update to the caller's profile;
+// fields left out of the request keep their current values.
+func (cfg *apiConfig) handlerUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	params := struct {
+		Username    *string `json:"username"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		AvatarURL   *string `json:"avatar_url"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	update := database.UpdateUserProfileParams{ID: claims.UserID}
+	if params.Username != nil {
+		if !usernamePattern.MatchString(*params.Username) {
+			w.WriteHeader(400)
+			return
+		}
+		taken, err := cfg.usernameTaken(r, *params.Username, claims.UserID)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if taken {
+			w.WriteHeader(409)
+			return
+		}
+		update.Username = sql.NullString{String: *params.Username, Valid: true}
+	}
+	if params.DisplayName != nil {
+		if utf8.RuneCountInString(*params.DisplayName) > maxDisplayNameLength {
+			w.WriteHeader(400)
+			return
+		}
+		update.DisplayName = sql.NullString{String: *params.DisplayName, Valid: true}
+	}
+	if params.Bio != nil {
+		if utf8.RuneCountInString(*params.Bio) > maxBioLength {
+			w.WriteHeader(400)
+			return
+		}
+		update.Bio = sql.NullString{String: *params.Bio, Valid: true}
+	}
+	if params.AvatarURL != nil {
+		if !validAvatarURL(*params.AvatarURL) {
+			w.WriteHeader(400)
+			return
+		}
+		update.AvatarUrl = sql.NullString{String: *params.AvatarURL, Valid: true}
+	}
+
+	user, err := cfg.Db.UpdateUserProfile(r.Context(), update)
+	// Someone else took the username after the check above.
+	if isUniqueViolation(err) {
+		w.WriteHeader(409)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("update profile error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(userValues(user))
+}
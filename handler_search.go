@@ -0,0 +1,194 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/pagination"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultUserSearchLimit = 10
+	maxUserSearchLimit     = 25
+)
+
+// likeEscaper escapes LIKE wildcards so user input only ever matches
+// literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchChirps delimits matches in its snippets with these private-use
+// characters rather than markup, so that the chirp text can be escaped
+// before the <mark> tags go in.
+var snippetHighlighter = strings.NewReplacer("\ue000", "<mark>", "\ue001", "</mark>")
+
+// highlightSnippet turns a snippet from SearchChirps into safe HTML: the
+// chirp's own text is escaped and only the <mark> tags are markup.
+func highlightSnippet(snippet string) string {
+	return snippetHighlighter.Replace(html.EscapeString(snippet))
+}
+
+type UserSearchResultRes struct {
+	Id          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+}
+
+type ChirpSearchResultRes struct {
+	ChirpRes
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type ChirpSearchRes struct {
+	Chirps     []ChirpSearchResultRes `json:"chirps"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// handlerSearchChirps runs a full-text search over chirp bodies. q accepts
+// web search syntax, so "quoted phrases", OR and -excluded words all work.
+// Results can be narrowed to one author by username and to a since/until
+// range, and come back best match first. Each snippet is HTML: the chirp
+// text escaped, with the matching words wrapped in <mark> tags.
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		w.WriteHeader(400)
+		return
+	}
+
+	page, pageErr := pagination.Parse(query)
+	if pageErr != nil || (page.After != nil && page.After.Rank == nil) {
+		w.WriteHeader(400)
+		return
+	}
+
+	params := database.SearchChirpsParams{Query: q, MaxResults: int32(page.Limit)}
+	for _, bound := range []struct {
+		name  string
+		value *sql.NullTime
+	}{{"since", &params.Since}, {"until", &params.Until}} {
+		if raw := query.Get(bound.name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			// created_at is a timestamp without time zone holding UTC, and
+			// Postgres drops any offset when binding to one.
+			*bound.value = sql.NullTime{Time: parsed.UTC(), Valid: true}
+		}
+	}
+	if author := query.Get("author"); author != "" {
+		user, err := cfg.Db.GetUserByUsername(r.Context(), author)
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.writeChirpSearch(w, r, page, nil)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		params.AuthorID = uuid.NullUUID{UUID: user.ID, Valid: true}
+	}
+	if page.After != nil {
+		params.AfterRank = sql.NullFloat64{Float64: *page.After.Rank, Valid: true}
+		params.AfterCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+
+	rows, err := cfg.Db.SearchChirps(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("search chirps error", err)
+		return
+	}
+
+	cfg.writeChirpSearch(w, r, page, rows)
+}
+
+func (cfg *apiConfig) writeChirpSearch(w http.ResponseWriter, r *http.Request, page pagination.Page, rows []database.SearchChirpsRow) {
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = database.Chirp{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			Body:        row.Body,
+			UserID:      row.UserID,
+			DeletedAt:   row.DeletedAt,
+			ReplyToID:   row.ReplyToID,
+			RepostOfID:  row.RepostOfID,
+			FannedOutAt: row.FannedOutAt,
+		}
+	}
+	res, err := cfg.chirpResponses(r.Context(), chirps)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	body := ChirpSearchRes{Chirps: []ChirpSearchResultRes{}}
+	for i, row := range rows {
+		body.Chirps = append(body.Chirps, ChirpSearchResultRes{ChirpRes: res[i], Rank: row.Rank, Snippet: highlightSnippet(row.Snippet)})
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		body.NextCursor = page.Next(len(rows), pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID, Rank: &last.Rank})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(body)
+}
+
+// handlerSearchUsers backs username autocomplete. Exact username matches come
+// first, then username and display name prefixes, then anything similar
+// enough by trigram to survive a typo.
+func (cfg *apiConfig) handlerSearchUsers(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	q = strings.TrimPrefix(q, "@")
+	if q == "" {
+		w.WriteHeader(400)
+		return
+	}
+	limit := defaultUserSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxUserSearchLimit {
+			w.WriteHeader(400)
+			return
+		}
+		limit = parsed
+	}
+
+	users, err := cfg.Db.SearchUsers(r.Context(), database.SearchUsersParams{
+		Query:      q,
+		Prefix:     likeEscaper.Replace(q) + "%",
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("search users error", err)
+		return
+	}
+
+	res := []UserSearchResultRes{}
+	for _, user := range users {
+		res = append(res, UserSearchResultRes{Id: user.ID, Username: user.Username, DisplayName: user.DisplayName, AvatarURL: user.AvatarUrl})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
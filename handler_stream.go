@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/stream"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const streamHeartbeatInterval = 15 * time.Second
+
+// newStreamListener opens a LISTEN connection for channel. It reconnects by
+// itself, so failures are only logged. Listen blocks until the first
+// connection succeeds, so it runs in the background rather than holding up
+// startup while the database is unreachable.
+func newStreamListener(dbURL, channel string) *pq.Listener {
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("%s listener: %v", channel, err)
+		}
+	})
+	go func() {
+		if err := listener.Listen(channel); err != nil {
+			log.Printf("listening on %s: %v", channel, err)
+		}
+	}()
+	return listener
+}
+
+// publishChirpEvent announces a chirp change to every instance's stream. The
+// stream is best effort, so failures are logged rather than returned.
+func (cfg *apiConfig) publishChirpEvent(ctx context.Context, eventType string, authorID uuid.UUID, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		fmt.Println("chirp event error", err)
+		return
+	}
+	err = cfg.Db.NotifyChirpEvent(ctx, database.NotifyChirpEventParams{Type: eventType, AuthorID: authorID, Data: payload})
+	if err != nil {
+		fmt.Println("chirp event error", err)
+	}
+}
+
+// handlerStreamChirps pushes chirp.created and chirp.deleted events as
+// Server-Sent Events, optionally only those by ?author=<username>. Clients
+// reconnecting with Last-Event-ID get the events they missed from the replay
+// buffer, or a reset event when the buffer no longer reaches back that far
+// and they should refetch. Slow clients are disconnected and can resume the
+// same way.
+func (cfg *apiConfig) handlerStreamChirps(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(500)
+		return
+	}
+
+	var lastID int64
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			w.WriteHeader(400)
+			return
+		}
+		lastID = parsed
+	}
+
+	var filter stream.Filter
+	if author := r.URL.Query().Get("author"); author != "" {
+		user, err := cfg.Db.GetUserByUsername(r.Context(), author)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(404)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		filter = func(event stream.Event) bool { return event.AuthorID == user.ID }
+	}
+
+	sub, backlog, complete := cfg.Stream.Subscribe(lastID, filter)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range backlog {
+		writeStreamEvent(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Dropped():
+			return
+		case event := <-sub.Events:
+			writeStreamEvent(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event stream.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
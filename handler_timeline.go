@@ -0,0 +1,115 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/pagination"
+	"github.com/BradDeA/chirpy.git/internal/timeline"
+	"github.com/google/uuid"
+)
+
+type ChirpPageRes struct {
+	Chirps     []ChirpRes `json:"chirps"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// handlerTimeline returns the caller's home timeline, newest first: their own
+// chirps and those of everyone they follow. With fan-out enabled it reads the
+// materialized timeline; otherwise it joins chirps against follows.
+func (cfg *apiConfig) handlerTimeline(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	page, pageErr := pagination.Parse(r.URL.Query())
+	if pageErr != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	params := database.GetTimelineParams{UserID: claims.UserID, MaxResults: int32(page.Limit)}
+	if page.After != nil {
+		params.BeforeCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	var chirps []database.Chirp
+	var err error
+	if cfg.Timeline != nil {
+		chirps, err = cfg.Db.GetCachedTimeline(r.Context(), database.GetCachedTimelineParams(params))
+	} else {
+		chirps, err = cfg.Db.GetTimeline(r.Context(), params)
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("timeline error", err)
+		return
+	}
+
+	cfg.writeChirpPage(w, r, page, chirps)
+}
+
+// writeChirpPage renders a page of chirps along with the cursor for the next
+// one.
+func (cfg *apiConfig) writeChirpPage(w http.ResponseWriter, r *http.Request, page pagination.Page, chirps []database.Chirp) {
+	res, err := cfg.chirpResponses(r.Context(), chirps)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	body := ChirpPageRes{Chirps: res}
+	if len(chirps) > 0 {
+		last := chirps[len(chirps)-1]
+		body.NextCursor = page.Next(len(chirps), pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (cfg *apiConfig) handlerRebuildTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, parseErr := uuid.Parse(r.PathValue("userID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	written, err := cfg.Timeline.Rebuild(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("timeline rebuild error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(struct {
+		Entries int64 `json:"entries"`
+	}{written})
+}
+
+func (cfg *apiConfig) handlerCheckTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, parseErr := uuid.Parse(r.PathValue("userID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	report, err := cfg.Timeline.Check(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("timeline check error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(struct {
+		timeline.Report
+		Consistent bool `json:"consistent"`
+	}{report, report.Consistent()})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+type PersonalTokenRes struct {
+	Id         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func personalTokenRes(pat database.PersonalAccessToken) PersonalTokenRes {
+	res := PersonalTokenRes{
+		Id:        pat.ID,
+		CreatedAt: pat.CreatedAt,
+		Name:      pat.Name,
+		Prefix:    pat.TokenPrefix,
+		Scopes:    pat.Scopes,
+	}
+	if pat.ExpiresAt.Valid {
+		res.ExpiresAt = &pat.ExpiresAt.Time
+	}
+	if pat.LastUsedAt.Valid {
+		res.LastUsedAt = &pat.LastUsedAt.Time
+	}
+	return res
+}
+
+// Personal access tokens can only be managed from the user's own login, so a
+// leaked token cannot be used to mint or list further tokens.
+func (cfg *apiConfig) handlerCreatePersonalToken(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	params := struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil || params.Name == "" || len(params.Scopes) == 0 || params.ExpiresInDays < 0 {
+		w.WriteHeader(400)
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !auth.ValidScope(scope) {
+			w.WriteHeader(400)
+			return
+		}
+	}
+
+	token, prefix, tokenErr := auth.MakePersonalAccessToken()
+	if tokenErr != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(time.Duration(params.ExpiresInDays) * 24 * time.Hour), Valid: true}
+	}
+
+	pat, createErr := cfg.Db.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:      claims.UserID,
+		Name:        params.Name,
+		TokenHash:   auth.HashToken(token),
+		TokenPrefix: prefix,
+		Scopes:      params.Scopes,
+		ExpiresAt:   expiresAt,
+	})
+	if createErr != nil {
+		w.WriteHeader(500)
+		fmt.Println("create personal token error", createErr)
+		return
+	}
+
+	res := personalTokenRes(pat)
+	res.Token = token
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (cfg *apiConfig) handlerListPersonalTokens(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	pats, err := cfg.Db.GetPersonalAccessTokensForUser(r.Context(), claims.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []PersonalTokenRes{}
+	for _, pat := range pats {
+		res = append(res, personalTokenRes(pat))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (cfg *apiConfig) handlerDeletePersonalToken(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	tokenID, parseErr := uuid.Parse(r.PathValue("tokenID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	deleted, err := cfg.Db.DeletePersonalAccessToken(r.Context(), database.DeletePersonalAccessTokenParams{ID: tokenID, UserID: claims.UserID})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	w.WriteHeader(204)
+}
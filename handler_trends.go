@@ -0,0 +1,66 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+)
+
+type TrendRes struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+	Uses  int64   `json:"uses"`
+}
+
+// handlerTrends returns the latest trending hashtags snapshot for the
+// requested window, or for the first configured window by default.
+func (cfg *apiConfig) handlerTrends(w http.ResponseWriter, r *http.Request) {
+	type TrendsRes struct {
+		Window     string     `json:"window"`
+		ComputedAt *time.Time `json:"computed_at"`
+		Trends     []TrendRes `json:"trends"`
+	}
+
+	window := r.URL.Query().Get("window")
+	known := false
+	for _, configured := range cfg.TrendWindows {
+		if window == "" {
+			window = configured.Name
+		}
+		if configured.Name == window {
+			known = true
+			break
+		}
+	}
+	if !known {
+		w.WriteHeader(400)
+		return
+	}
+
+	res := TrendsRes{Window: window, Trends: []TrendRes{}}
+	computedAt, err := cfg.Db.GetLatestTrendComputation(r.Context(), window)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(500)
+		return
+	}
+	// Until the first computation there is nothing to show.
+	if err == nil {
+		snapshot, err := cfg.Db.GetTrendSnapshot(r.Context(), database.GetTrendSnapshotParams{WindowName: window, ComputedAt: computedAt})
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		res.ComputedAt = &computedAt
+		for _, trend := range snapshot {
+			res.Trends = append(res.Trends, TrendRes{Tag: trend.Tag, Score: trend.Score, Uses: trend.Uses})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/pagination"
+	"github.com/BradDeA/chirpy.git/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+type WebhookRes struct {
+	Id        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	AllUsers  bool      `json:"all_users"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+type WebhookDeliveryRes struct {
+	Id             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	ResponseStatus *int32          `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+func webhookRes(webhook database.Webhook) WebhookRes {
+	return WebhookRes{
+		Id:        webhook.ID,
+		CreatedAt: webhook.CreatedAt,
+		Url:       webhook.Url,
+		Events:    webhook.Events,
+		AllUsers:  webhook.AllUsers,
+	}
+}
+
+func webhookDeliveryRes(delivery database.WebhookDelivery) WebhookDeliveryRes {
+	res := WebhookDeliveryRes{
+		Id:        delivery.ID,
+		CreatedAt: delivery.CreatedAt,
+		Event:     delivery.Event,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		Payload:   delivery.Payload,
+	}
+	if delivery.Status == webhooks.StatusPending {
+		res.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastAttemptAt.Valid {
+		res.LastAttemptAt = &delivery.LastAttemptAt.Time
+	}
+	if delivery.ResponseStatus.Valid {
+		res.ResponseStatus = &delivery.ResponseStatus.Int32
+	}
+	if delivery.LastError.Valid {
+		res.LastError = &delivery.LastError.String
+	}
+	return res
+}
+
+// enqueueWebhooks queues a delivery of event to every webhook subscribed to
+// it: those owned by userID and the admin webhooks that see every user.
+// Failing to queue never fails the request that caused the event.
+func (cfg *apiConfig) enqueueWebhooks(ctx context.Context, event string, userID uuid.UUID, data any) {
+	payload, err := webhooks.Payload(event, data)
+	if err != nil {
+		fmt.Println("webhook payload error", err)
+		return
+	}
+	_, err = cfg.Db.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{Event: event, Payload: payload, UserID: userID})
+	if err != nil {
+		fmt.Println("enqueue webhooks error", err)
+	}
+}
+
+// userCreated tells webhooks about a new account.
+func (cfg *apiConfig) userCreated(ctx context.Context, user database.User) {
+	cfg.enqueueWebhooks(ctx, webhooks.EventUserCreated, user.ID, profileRes(user))
+}
+
+func (cfg *apiConfig) handlerCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	cfg.createWebhook(w, r, false)
+}
+
+// handlerCreateAdminWebhook registers a webhook that receives events for
+// every user, including user.created.
+func (cfg *apiConfig) handlerCreateAdminWebhook(w http.ResponseWriter, r *http.Request) {
+	cfg.createWebhook(w, r, true)
+}
+
+// Webhooks can only be managed from the user's own login, like personal
+// access tokens. The signing secret is only ever returned on creation.
+func (cfg *apiConfig) createWebhook(w http.ResponseWriter, r *http.Request, allUsers bool) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	params := struct {
+		Url    string   `json:"url"`
+		Events []string `json:"events"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil || len(params.Events) == 0 {
+		w.WriteHeader(400)
+		return
+	}
+	target, urlErr := url.Parse(params.Url)
+	if urlErr != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		w.WriteHeader(400)
+		return
+	}
+	// Only admins may point webhooks at the server itself or at hosts on
+	// its private network.
+	if !allUsers && webhooks.CheckHost(r.Context(), target.Hostname()) != nil {
+		w.WriteHeader(400)
+		return
+	}
+	for _, event := range params.Events {
+		// A new user has no webhooks of their own, so only admin webhooks
+		// can ever receive user.created.
+		if !webhooks.ValidEvent(event) || (event == webhooks.EventUserCreated && !allUsers) {
+			w.WriteHeader(400)
+			return
+		}
+	}
+
+	secret, secretErr := webhooks.NewSecret()
+	if secretErr != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	webhook, createErr := cfg.Db.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		UserID:   claims.UserID,
+		Url:      target.String(),
+		Secret:   secret,
+		Events:   params.Events,
+		AllUsers: allUsers,
+	})
+	if createErr != nil {
+		w.WriteHeader(500)
+		fmt.Println("create webhook error", createErr)
+		return
+	}
+
+	res := webhookRes(webhook)
+	res.Secret = secret
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (cfg *apiConfig) handlerListWebhooks(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	list, err := cfg.Db.GetWebhooksForUser(r.Context(), claims.UserID)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []WebhookRes{}
+	for _, webhook := range list {
+		res = append(res, webhookRes(webhook))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (cfg *apiConfig) handlerDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return
+	}
+
+	webhookID, parseErr := uuid.Parse(r.PathValue("webhookID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	deleted, err := cfg.Db.DeleteWebhook(r.Context(), database.DeleteWebhookParams{ID: webhookID, UserID: claims.UserID})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(404)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// ownedWebhook looks up the webhook named in the path, writing a 404 for
+// webhooks that do not exist or belong to someone else.
+func (cfg *apiConfig) ownedWebhook(w http.ResponseWriter, r *http.Request) (database.Webhook, bool) {
+	claims, _ := auth.UserFromContext(r.Context())
+	if claims.Delegated() {
+		w.WriteHeader(403)
+		return database.Webhook{}, false
+	}
+
+	webhookID, parseErr := uuid.Parse(r.PathValue("webhookID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return database.Webhook{}, false
+	}
+
+	webhook, err := cfg.Db.GetWebhookForUser(r.Context(), database.GetWebhookForUserParams{ID: webhookID, UserID: claims.UserID})
+	if err == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return database.Webhook{}, false
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		return database.Webhook{}, false
+	}
+	return webhook, true
+}
+
+// handlerWebhookDeliveries is the delivery log of one webhook, newest first,
+// so its owner can see what was sent, how the receiver answered and which
+// deliveries gave up.
+func (cfg *apiConfig) handlerWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	type DeliveryPageRes struct {
+		Deliveries []WebhookDeliveryRes `json:"deliveries"`
+		NextCursor string               `json:"next_cursor,omitempty"`
+	}
+
+	webhook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	page, pageErr := pagination.Parse(r.URL.Query())
+	if pageErr != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	params := database.GetWebhookDeliveriesParams{WebhookID: webhook.ID, MaxResults: int32(page.Limit)}
+	if page.After != nil {
+		params.BeforeCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	deliveries, err := cfg.Db.GetWebhookDeliveries(r.Context(), params)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Println("webhook deliveries error", err)
+		return
+	}
+
+	res := DeliveryPageRes{Deliveries: []WebhookDeliveryRes{}}
+	for _, delivery := range deliveries {
+		res.Deliveries = append(res.Deliveries, webhookDeliveryRes(delivery))
+	}
+	if len(deliveries) > 0 {
+		last := deliveries[len(deliveries)-1]
+		res.NextCursor = page.Next(len(deliveries), pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
+// handlerRetryWebhookDelivery sends a dead delivery again, with a fresh set
+// of attempts. Anything but a dead delivery of this webhook is a 409.
+func (cfg *apiConfig) handlerRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, parseErr := uuid.Parse(r.PathValue("deliveryID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	retried, err := cfg.Db.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{ID: deliveryID, WebhookID: webhook.ID})
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if retried == 0 {
+		w.WriteHeader(409)
+		return
+	}
+	w.WriteHeader(202)
+}
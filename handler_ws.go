@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/auth"
+	"github.com/BradDeA/chirpy.git/internal/realtime"
+	"github.com/BradDeA/chirpy.git/internal/stream"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+var socketUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// socketRecheckInterval is how often an open connection's token is checked
+// again, so that logging out or deleting the account also ends it.
+const socketRecheckInterval = 30 * time.Second
+
+// socketUpdate is the outcome of one client message: a change to the
+// connection's topics, which only the event loop may apply, and the reply.
+type socketUpdate struct {
+	apply func(*realtime.Topics)
+	reply realtime.ServerMessage
+}
+
+// handlerWebSocket serves the realtime API for one authenticated user.
+// Clients send {"type": "subscribe"|"unsubscribe", "topic": ...} for the
+// timeline, their notifications, or a thread given by chirp_id, and receive
+// {"type": "event", ...} messages for whatever they are subscribed to. The
+// server pings every 30 seconds and drops clients that stop answering, fall
+// too far behind, or whose token expires or is revoked.
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.UserFromContext(r.Context())
+
+	ws, err := socketUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error status.
+		return
+	}
+	conn := realtime.NewConn(ws, realtime.DefaultSendBuffer)
+	defer conn.Close(websocket.CloseNormalClosure, "")
+	go cfg.Sockets.Run(conn)
+
+	chirps, _, _ := cfg.Stream.Subscribe(0, nil)
+	defer chirps.Close()
+	notifications, _, _ := cfg.Notifications.Subscribe(0, func(event stream.Event) bool {
+		return event.UserID == claims.UserID
+	})
+	defer notifications.Close()
+
+	messages := make(chan realtime.ClientMessage)
+	go conn.ReadPump(messages)
+
+	// Subscribing can mean database lookups. They run here, one message at
+	// a time, so the event loop keeps draining the hubs meanwhile.
+	updates := make(chan socketUpdate)
+	go func() {
+		defer close(updates)
+		for msg := range messages {
+			select {
+			case updates <- cfg.handleSocketMessage(r.Context(), claims.UserID, msg):
+			case <-conn.Done():
+				return
+			}
+		}
+	}()
+
+	var expired <-chan time.Time
+	if claims.ExpiresAt != nil {
+		timer := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	recheck := time.NewTicker(socketRecheckInterval)
+	defer recheck.Stop()
+	checked := make(chan error, 1)
+	checking := false
+
+	topics := realtime.NewTopics()
+	for {
+		select {
+		case <-conn.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			if update.apply != nil {
+				update.apply(topics)
+			}
+			conn.Send(update.reply)
+		case event := <-chirps.Events:
+			for _, match := range topics.MatchChirpEvent(event) {
+				conn.Send(realtime.ServerMessage{Type: "event", Topic: match.Topic, ChirpID: match.ChirpID, Event: event.Type, Data: event.Data})
+			}
+		case event := <-notifications.Events:
+			if topics.Notifications() {
+				conn.Send(realtime.ServerMessage{Type: "event", Topic: realtime.TopicNotifications, Event: event.Type, Data: event.Data})
+			}
+		case <-chirps.Dropped():
+			conn.Close(websocket.CloseTryAgainLater, "slow consumer")
+			return
+		case <-notifications.Dropped():
+			conn.Close(websocket.CloseTryAgainLater, "slow consumer")
+			return
+		case <-expired:
+			conn.Close(websocket.ClosePolicyViolation, "token expired")
+			return
+		case <-recheck.C:
+			if checking {
+				break
+			}
+			checking = true
+			go func() {
+				// Authenticating the upgrade request again applies the
+				// denylist and any personal access token deletion.
+				_, err := cfg.Auth.Authenticate(r)
+				checked <- err
+			}()
+		case err := <-checked:
+			checking = false
+			if err == nil {
+				break
+			}
+			if !auth.IsTokenError(err) {
+				// A failure to check is not a revocation.
+				fmt.Println("websocket token check error", err)
+				break
+			}
+			conn.Close(websocket.ClosePolicyViolation, "token revoked")
+			return
+		}
+	}
+}
+
+// handleSocketMessage turns a subscribe or unsubscribe request into the
+// topic change to make and the reply. The timeline follows whoever the user
+// followed at the time of subscribing; clients resubscribe to pick up follow
+// changes.
+func (cfg *apiConfig) handleSocketMessage(ctx context.Context, userID uuid.UUID, msg realtime.ClientMessage) socketUpdate {
+	fail := func(reason string) socketUpdate {
+		return socketUpdate{reply: realtime.ServerMessage{Type: "error", Topic: msg.Topic, ChirpID: msg.ChirpID, Error: reason}}
+	}
+	if msg.Type != "subscribe" && msg.Type != "unsubscribe" {
+		return fail("unknown message type")
+	}
+	subscribe := msg.Type == "subscribe"
+
+	var apply func(*realtime.Topics)
+	switch msg.Topic {
+	case realtime.TopicTimeline:
+		if !subscribe {
+			apply = (*realtime.Topics).UnsubscribeTimeline
+			break
+		}
+		followees, err := cfg.Db.GetFolloweeIDs(ctx, userID)
+		if err != nil {
+			fmt.Println("websocket timeline error", err)
+			return fail("internal error")
+		}
+		authors := append(followees, userID)
+		apply = func(topics *realtime.Topics) { topics.SubscribeTimeline(authors) }
+	case realtime.TopicNotifications:
+		apply = func(topics *realtime.Topics) { topics.SetNotifications(subscribe) }
+	case realtime.TopicThread:
+		if msg.ChirpID == nil {
+			return fail("chirp_id is required")
+		}
+		root := *msg.ChirpID
+		if !subscribe {
+			apply = func(topics *realtime.Topics) { topics.UnsubscribeThread(root) }
+			break
+		}
+		chirp, err := cfg.Db.GetChirp(ctx, root)
+		if err == sql.ErrNoRows {
+			return fail("chirp not found")
+		}
+		if err != nil {
+			return fail("internal error")
+		}
+		replies, err := cfg.Db.GetChirpDescendantIDs(ctx, chirp.ID)
+		if err != nil {
+			fmt.Println("websocket thread error", err)
+			return fail("internal error")
+		}
+		apply = func(topics *realtime.Topics) { topics.SubscribeThread(chirp.ID, replies) }
+	default:
+		return fail("unknown topic")
+	}
+
+	return socketUpdate{apply: apply, reply: realtime.ServerMessage{Type: msg.Type + "d", Topic: msg.Topic, ChirpID: msg.ChirpID}}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -26,9 +27,90 @@ func CheckPasswordHash(password, hash string) error {
 	return result
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
-	expirationTime := time.Now().Add(time.Hour)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Issuer: "chirpy", IssuedAt: jwt.NewNumericDate(time.Now()), ExpiresAt: jwt.NewNumericDate(expirationTime), Subject: userID.String()})
+const (
+	Issuer          = "chirpy"
+	DefaultAudience = "chirpy-api"
+	DefaultTokenTTL = time.Hour
+)
+
+var (
+	ErrTokenExpired   = errors.New("token is expired")
+	ErrTokenMalformed = errors.New("token is malformed")
+	ErrTokenSignature = errors.New("token signature is invalid")
+	ErrTokenClaims    = errors.New("token claims are invalid")
+)
+
+// TokenOptions customises an access token issued by MakeJWT. A zero TTL
+// falls back to DefaultTokenTTL, an empty Audience to DefaultAudience and an
+// empty ID to a freshly generated jti. AuthTime, when set, is when the user
+// signed in; tokens issued from a refresh token leave it out.
+type TokenOptions struct {
+	TTL      time.Duration
+	Audience string
+	ID       string
+	Role     string
+	Tier     string
+	Scope    string
+	ClientID string
+	AuthTime time.Time
+}
+
+// ValidateOptions controls which access tokens ValidateJWT accepts. An empty
+// Audience falls back to DefaultAudience.
+type ValidateOptions struct {
+	Audience string
+	Leeway   time.Duration
+}
+
+// Claims is the payload of a Chirpy access token. UserID is the parsed
+// subject and is filled in by ValidateJWT; Personal marks claims that came
+// from a personal access token rather than a JWT. Neither is serialised.
+type Claims struct {
+	jwt.RegisteredClaims
+	Role     string           `json:"role,omitempty"`
+	Tier     string           `json:"tier,omitempty"`
+	Scope    string           `json:"scope,omitempty"`
+	ClientID string           `json:"client_id,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	UserID   uuid.UUID        `json:"-"`
+	Personal bool             `json:"-"`
+}
+
+func MakeJWT(userID uuid.UUID, tokenSecret string, opts TokenOptions) (string, error) {
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = DefaultTokenTTL
+	}
+	audience := opts.Audience
+	if audience == "" {
+		audience = DefaultAudience
+	}
+	tokenID := opts.ID
+	if tokenID == "" {
+		tokenID = uuid.NewString()
+	}
+
+	var authTime *jwt.NumericDate
+	if !opts.AuthTime.IsZero() {
+		authTime = jwt.NewNumericDate(opts.AuthTime)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			Subject:   userID.String(),
+		},
+		Role:     opts.Role,
+		Tier:     opts.Tier,
+		Scope:    opts.Scope,
+		ClientID: opts.ClientID,
+		AuthTime: authTime,
+	})
 	tokenString, err := token.SignedString([]byte(tokenSecret))
 	if err != nil {
 		return "", err
@@ -36,27 +118,59 @@ func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 	return tokenString, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+func ValidateJWT(tokenString, tokenSecret string, opts ValidateOptions) (*Claims, error) {
+	audience := opts.Audience
+	if audience == "" {
+		audience = DefaultAudience
+	}
+
 	keyFunc := func(token *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, keyFunc)
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithLeeway(opts.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil {
-		return uuid.Nil, err
-	}
-
-	userId, claimErr := token.Claims.GetSubject()
-	if claimErr != nil {
-		return uuid.Nil, claimErr
+		return nil, classifyJWTError(err)
 	}
 
-	uuidParse, uuidErr := uuid.Parse(userId)
+	uuidParse, uuidErr := uuid.Parse(claims.Subject)
 	if uuidErr != nil {
-		return uuid.Nil, uuidErr
+		return nil, fmt.Errorf("%w: subject: %v", ErrTokenMalformed, uuidErr)
 	}
+	claims.UserID = uuidParse
+
+	return claims, nil
+}
 
-	return uuidParse, nil
+// Delegated reports whether the claims come from a credential acting on the
+// user's behalf (a personal access token or a third-party OAuth client)
+// rather than from the user's own login.
+func (c *Claims) Delegated() bool {
+	return c.Personal || c.ClientID != ""
+}
+
+// classifyJWTError maps the jwt library's errors onto the package's own
+// sentinels so callers can tell them apart with errors.Is without importing
+// the jwt package. The original error is kept in the message for logging.
+func classifyJWTError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return fmt.Errorf("%w: %v", ErrTokenSignature, err)
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return fmt.Errorf("%w: %v", ErrTokenExpired, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrTokenClaims, err)
+	}
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
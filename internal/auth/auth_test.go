@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -62,50 +64,206 @@ func TestCheckPasswordHash(t *testing.T) {
 	}
 }
 
+func signTestClaims(t *testing.T, method jwt.SigningMethod, claims jwt.Claims, key interface{}) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("signing test token: %v", err)
+	}
+	return token
+}
+
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
+	validToken, _ := MakeJWT(userID, "secret", TokenOptions{})
+	expiredToken, _ := MakeJWT(userID, "secret", TokenOptions{TTL: -time.Minute})
+
+	baseClaims := func() jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{DefaultAudience},
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}
+
+	wrongIssuer := baseClaims()
+	wrongIssuer.Issuer = "someone-else"
+	wrongAudience := baseClaims()
+	wrongAudience.Audience = jwt.ClaimStrings{"other-api"}
+	noExpiry := baseClaims()
+	noExpiry.ExpiresAt = nil
+	badSubject := baseClaims()
+	badSubject.Subject = "not-a-uuid"
 
 	tests := []struct {
 		name        string
 		tokenString string
 		tokenSecret string
+		opts        ValidateOptions
 		wantUserID  uuid.UUID
-		wantErr     bool
+		wantErr     error
 	}{
 		{
 			name:        "Valid token",
 			tokenString: validToken,
 			tokenSecret: "secret",
 			wantUserID:  userID,
-			wantErr:     false,
 		},
 		{
 			name:        "Invalid token",
 			tokenString: "invalid.token.string",
 			tokenSecret: "secret",
 			wantUserID:  uuid.Nil,
-			wantErr:     true,
+			wantErr:     ErrTokenMalformed,
 		},
 		{
 			name:        "Wrong secret",
 			tokenString: validToken,
 			tokenSecret: "wrong_secret",
 			wantUserID:  uuid.Nil,
-			wantErr:     true,
+			wantErr:     ErrTokenSignature,
+		},
+		{
+			name:        "Expired token",
+			tokenString: expiredToken,
+			tokenSecret: "secret",
+			wantUserID:  uuid.Nil,
+			wantErr:     ErrTokenExpired,
+		},
+		{
+			name:        "Expired token within leeway",
+			tokenString: expiredToken,
+			tokenSecret: "secret",
+			opts:        ValidateOptions{Leeway: 5 * time.Minute},
+			wantUserID:  userID,
+		},
+		{
+			name:        "Disallowed signing method",
+			tokenString: signTestClaims(t, jwt.SigningMethodHS512, baseClaims(), []byte("secret")),
+			tokenSecret: "secret",
+			wantUserID:  uuid.Nil,
+			wantErr:     ErrTokenSignature,
+		},
+		{
+			name:        "Unsigned token",
+			tokenString: signTestClaims(t, jwt.SigningMethodNone, baseClaims(), jwt.UnsafeAllowNoneSignatureType),
+			tokenSecret: "secret",
+			wantUserID:  uuid.Nil,
+			wantErr:     ErrTokenSignature,
+		},
+		{
+			name:        "Wrong issuer",
+			tokenString: signTestClaims(t, jwt.SigningMethodHS256, wrongIssuer, []byte("secret")),
+			tokenSecret: "secret",
+			wantUserID:  uuid.Nil,
+			wantErr:     ErrTokenClaims,
+		},
+		{
+			name:        "Wrong audience",
+			tokenString: signTestClaims(t, jwt.SigningMethodHS256, wrongAudience, []byte("secret")),
+			tokenSecret: "secret",
+			wantUserID:  uuid.Nil,
+			wantErr:     ErrTokenClaims,
+		},
+		{
+			name:        "Configured audience rejects default",
+			tokenString: validToken,
+			tokenSecret: "secret",
+			opts:        ValidateOptions{Audience: "other-api"},
+			wantUserID:  uuid.Nil,
+			wantErr:     ErrTokenClaims,
+		},
+		{
+			name:        "Missing expiry",
+			tokenString: signTestClaims(t, jwt.SigningMethodHS256, noExpiry, []byte("secret")),
+			tokenSecret: "secret",
+			wantUserID:  uuid.Nil,
+			wantErr:     ErrTokenClaims,
+		},
+		{
+			name:        "Subject is not a UUID",
+			tokenString: signTestClaims(t, jwt.SigningMethodHS256, badSubject, []byte("secret")),
+			tokenSecret: "secret",
+			wantUserID:  uuid.Nil,
+			wantErr:     ErrTokenMalformed,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
-			if (err != nil) != tt.wantErr {
+			claims, err := ValidateJWT(tt.tokenString, tt.tokenSecret, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
 				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			gotUserID := uuid.Nil
+			if claims != nil {
+				gotUserID = claims.UserID
+			}
 			if gotUserID != tt.wantUserID {
 				t.Errorf("ValidateJWT() gotUserID = %v, want %v", gotUserID, tt.wantUserID)
 			}
 		})
 	}
 }
+
+func TestMakeJWTOptions(t *testing.T) {
+	userID := uuid.New()
+	token, err := MakeJWT(userID, "secret", TokenOptions{
+		TTL:      10 * time.Minute,
+		Audience: "mobile",
+		ID:       "token-1",
+		Role:     "admin",
+		Tier:     "premium",
+		AuthTime: time.Unix(1700000000, 0),
+	})
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+
+	claims, err := ValidateJWT(token, "secret", ValidateOptions{Audience: "mobile"})
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if claims.UserID != userID {
+		t.Errorf("UserID = %v, want %v", claims.UserID, userID)
+	}
+	if claims.ID != "token-1" {
+		t.Errorf("ID = %q, want %q", claims.ID, "token-1")
+	}
+	if claims.Role != "admin" || claims.Tier != "premium" {
+		t.Errorf("Role, Tier = %q, %q, want %q, %q", claims.Role, claims.Tier, "admin", "premium")
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != 10*time.Minute {
+		t.Errorf("token lifetime = %v, want %v", ttl, 10*time.Minute)
+	}
+	if claims.AuthTime == nil || claims.AuthTime.Unix() != 1700000000 {
+		t.Errorf("AuthTime = %v, want %v", claims.AuthTime, time.Unix(1700000000, 0))
+	}
+}
+
+func TestMakeJWTDefaults(t *testing.T) {
+	userID := uuid.New()
+	first, _ := MakeJWT(userID, "secret", TokenOptions{})
+	second, _ := MakeJWT(userID, "secret", TokenOptions{})
+
+	firstClaims, err := ValidateJWT(first, "secret", ValidateOptions{})
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	secondClaims, err := ValidateJWT(second, "secret", ValidateOptions{})
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if firstClaims.ID == "" || firstClaims.ID == secondClaims.ID {
+		t.Errorf("expected unique non-empty jti, got %q and %q", firstClaims.ID, secondClaims.ID)
+	}
+	if ttl := firstClaims.ExpiresAt.Sub(firstClaims.IssuedAt.Time); ttl != DefaultTokenTTL {
+		t.Errorf("token lifetime = %v, want %v", ttl, DefaultTokenTTL)
+	}
+	if firstClaims.AuthTime != nil {
+		t.Errorf("AuthTime = %v, want none", firstClaims.AuthTime)
+	}
+}
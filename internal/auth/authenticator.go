@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// IsTokenError reports whether err from Authenticate means the token itself
+// was rejected. Any other error is a failure to check it, such as the
+// denylist's database being unreachable, and is not the client's fault.
+func IsTokenError(err error) bool {
+	for _, tokenErr := range []error{ErrTokenMalformed, ErrTokenSignature, ErrTokenExpired, ErrTokenClaims, ErrTokenRevoked} {
+		if errors.Is(err, tokenErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator turns the bearer token on a request into Claims. It is the
+// single place access tokens are validated, so every protected route gets the
+// same signature, claim and denylist checks.
+type Authenticator struct {
+	Secret         string
+	Options        ValidateOptions
+	Denylist       Denylist
+	PersonalTokens PersonalTokenLookup
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (*Claims, error) {
+	token, err := GetBearerToken(r.Header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	}
+
+	if IsPersonalAccessToken(token) {
+		if a.PersonalTokens == nil {
+			return nil, fmt.Errorf("%w: personal access tokens are not accepted", ErrTokenSignature)
+		}
+		return a.PersonalTokens.LookupPersonalAccessToken(r.Context(), token)
+	}
+
+	claims, err := ValidateJWT(token, a.Secret, a.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ID == "" {
+		return nil, fmt.Errorf("%w: missing jti", ErrTokenClaims)
+	}
+
+	revoked, err := a.Revoked(r.Context(), claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// Revoked reports whether a valid access token has since been revoked, on
+// its own or along with every other token of its user.
+func (a *Authenticator) Revoked(ctx context.Context, claims *Claims) (bool, error) {
+	if a.Denylist == nil {
+		return false, nil
+	}
+	denied, err := a.Denylist.Contains(ctx, claims.ID)
+	if err != nil || denied {
+		return denied, err
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	return a.Denylist.ContainsUser(ctx, claims.UserID, issuedAt)
+}
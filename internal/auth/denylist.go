@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// Denylist records access token IDs (jti) that must be rejected before their
+// natural expiry, e.g. after a logout. AddUser does the same for every token
+// a user was issued up to deniedBefore, e.g. after deleting the account.
+// Entries only need to be kept until the tokens would have expired anyway;
+// Purge drops the ones past that point.
+type Denylist interface {
+	Add(ctx context.Context, jti string, expiresAt time.Time) error
+	Contains(ctx context.Context, jti string) (bool, error)
+	AddUser(ctx context.Context, userID uuid.UUID, deniedBefore, expiresAt time.Time) error
+	ContainsUser(ctx context.Context, userID uuid.UUID, issuedAt time.Time) (bool, error)
+	Purge(ctx context.Context) error
+}
+
+type deniedUser struct {
+	deniedBefore time.Time
+	expiresAt    time.Time
+}
+
+type MemoryDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	users   map[uuid.UUID]deniedUser
+	now     func() time.Time
+}
+
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{entries: make(map[string]time.Time), users: make(map[uuid.UUID]deniedUser), now: time.Now}
+}
+
+func (d *MemoryDenylist) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[jti] = expiresAt
+	return nil
+}
+
+func (d *MemoryDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expiresAt, ok := d.entries[jti]
+	if !ok {
+		return false, nil
+	}
+	if !d.now().Before(expiresAt) {
+		delete(d.entries, jti)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (d *MemoryDenylist) AddUser(ctx context.Context, userID uuid.UUID, deniedBefore, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry := d.users[userID]
+	if deniedBefore.After(entry.deniedBefore) {
+		entry.deniedBefore = deniedBefore
+	}
+	if expiresAt.After(entry.expiresAt) {
+		entry.expiresAt = expiresAt
+	}
+	d.users[userID] = entry
+	return nil
+}
+
+func (d *MemoryDenylist) ContainsUser(ctx context.Context, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.users[userID]
+	if !ok {
+		return false, nil
+	}
+	if !d.now().Before(entry.expiresAt) {
+		delete(d.users, userID)
+		return false, nil
+	}
+	return !issuedAt.After(entry.deniedBefore), nil
+}
+
+func (d *MemoryDenylist) Purge(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	for jti, expiresAt := range d.entries {
+		if !now.Before(expiresAt) {
+			delete(d.entries, jti)
+		}
+	}
+	for userID, entry := range d.users {
+		if !now.Before(entry.expiresAt) {
+			delete(d.users, userID)
+		}
+	}
+	return nil
+}
+
+type DBDenylist struct {
+	Db *database.Queries
+}
+
+func NewDBDenylist(db *database.Queries) *DBDenylist {
+	return &DBDenylist{Db: db}
+}
+
+func (d *DBDenylist) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	return d.Db.DenyAccessToken(ctx, database.DenyAccessTokenParams{Jti: jti, ExpiresAt: expiresAt})
+}
+
+func (d *DBDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	return d.Db.IsAccessTokenDenied(ctx, jti)
+}
+
+func (d *DBDenylist) AddUser(ctx context.Context, userID uuid.UUID, deniedBefore, expiresAt time.Time) error {
+	return d.Db.DenyUserAccessTokens(ctx, database.DenyUserAccessTokensParams{UserID: userID, DeniedBefore: deniedBefore, ExpiresAt: expiresAt})
+}
+
+func (d *DBDenylist) ContainsUser(ctx context.Context, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	return d.Db.IsUserAccessTokenDenied(ctx, database.IsUserAccessTokenDeniedParams{UserID: userID, IssuedAt: issuedAt})
+}
+
+func (d *DBDenylist) Purge(ctx context.Context) error {
+	if err := d.Db.DeleteExpiredDeniedAccessTokens(ctx); err != nil {
+		return err
+	}
+	return d.Db.DeleteExpiredDeniedUsers(ctx)
+}
+
+// RunDenylistPurger calls Purge every interval until ctx is cancelled.
+func RunDenylistPurger(ctx context.Context, denylist Denylist, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := denylist.Purge(ctx); err != nil {
+				log.Printf("purging access token denylist: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryDenylist(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	denylist := NewMemoryDenylist()
+	denylist.now = func() time.Time { return now }
+
+	denylist.Add(ctx, "live", now.Add(time.Minute))
+	denylist.Add(ctx, "stale", now.Add(-time.Minute))
+
+	if denied, _ := denylist.Contains(ctx, "live"); !denied {
+		t.Errorf("Contains(live) = false, want true")
+	}
+	if denied, _ := denylist.Contains(ctx, "stale"); denied {
+		t.Errorf("Contains(stale) = true, want false")
+	}
+	if denied, _ := denylist.Contains(ctx, "unknown"); denied {
+		t.Errorf("Contains(unknown) = true, want false")
+	}
+
+	now = now.Add(2 * time.Minute)
+	denylist.Purge(ctx)
+	if len(denylist.entries) != 0 {
+		t.Errorf("Purge() left %d entries, want 0", len(denylist.entries))
+	}
+}
+
+func TestAuthenticateDenylist(t *testing.T) {
+	authenticator := &Authenticator{Secret: "secret", Denylist: NewMemoryDenylist()}
+	token, _ := MakeJWT(uuid.New(), "secret", TokenOptions{ID: "session-1"})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	claims, err := authenticator.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	authenticator.Denylist.Add(req.Context(), claims.ID, claims.ExpiresAt.Time)
+	if _, err := authenticator.Authenticate(req); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() after deny error = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+type failingDenylist struct{ *MemoryDenylist }
+
+func (failingDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestRequireAuthDenylistUnavailable(t *testing.T) {
+	authenticator := &Authenticator{Secret: "secret", Denylist: failingDenylist{NewMemoryDenylist()}}
+	token, _ := MakeJWT(uuid.New(), "secret", TokenOptions{ID: "session-1"})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	authenticator.RequireAuth(next).ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestAuthenticateDeniedUser(t *testing.T) {
+	authenticator := &Authenticator{Secret: "secret", Denylist: NewMemoryDenylist()}
+	userID := uuid.New()
+	before, _ := MakeJWT(userID, "secret", TokenOptions{ID: "session-1"})
+	other, _ := MakeJWT(uuid.New(), "secret", TokenOptions{ID: "session-2"})
+	authenticator.Denylist.AddUser(context.Background(), userID, time.Now(), time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "Token issued before denial", token: before, wantErr: ErrTokenRevoked},
+		{name: "Another user's token", token: other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			if _, err := authenticator.Authenticate(req); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
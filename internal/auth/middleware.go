@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+type contextKey int
+
+const claimsContextKey contextKey = iota
+
+func ContextWithUser(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+// UserFromContext returns the claims stored by RequireAuth or OptionalAuth.
+// The second result is false for anonymous requests.
+func UserFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// RequireAuth rejects requests without a valid access token and otherwise
+// passes the token's claims to next through the request context.
+func (a *Authenticator) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		a.serveAuthenticated(w, r, next)
+	})
+}
+
+// OptionalAuth lets anonymous requests through untouched, but a request that
+// does present a token must present a valid one.
+func (a *Authenticator) OptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		a.serveAuthenticated(w, r, next)
+	})
+}
+
+func (a *Authenticator) serveAuthenticated(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	claims, err := a.Authenticate(r)
+	if err != nil && !IsTokenError(err) {
+		log.Println("checking access token:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		log.Println("rejected access token:", err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy", error="invalid_token"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), claims)))
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const PersonalTokenPrefix = "chirpy_pat_"
+
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileWrite = "profile:write"
+)
+
+var knownScopes = map[string]bool{
+	ScopeChirpsRead:   true,
+	ScopeChirpsWrite:  true,
+	ScopeProfileWrite: true,
+}
+
+func ValidScope(scope string) bool {
+	return knownScopes[scope]
+}
+
+// PersonalTokenLookup resolves a raw personal access token to the claims it
+// grants. Implementations must reject expired tokens.
+type PersonalTokenLookup interface {
+	LookupPersonalAccessToken(ctx context.Context, token string) (*Claims, error)
+}
+
+// MakePersonalAccessToken returns a new random token and the short prefix
+// that is safe to store and show back to the user for identification.
+func MakePersonalAccessToken() (token string, displayPrefix string, err error) {
+	random, err := MakeRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+	token = PersonalTokenPrefix + random
+	return token, token[:len(PersonalTokenPrefix)+6], nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalTokenPrefix)
+}
+
+// HashToken hashes a high-entropy token for storage. Unlike passwords these
+// are random, so a fast hash is enough and allows lookup by hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HasScope reports whether the token grants scope. Session tokens from
+// /api/login carry no scope and are unrestricted.
+func (c *Claims) HasScope(scope string) bool {
+	if c.Scope == "" {
+		return true
+	}
+	for _, granted := range strings.Fields(c.Scope) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope authenticates like RequireAuth and additionally rejects
+// tokens that were not granted scope.
+func (a *Authenticator) RequireScope(scope string, next http.Handler) http.Handler {
+	return a.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := UserFromContext(r.Context())
+		if !claims.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error="insufficient_scope", scope=%q`, scope))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+type DBPersonalTokens struct {
+	Db *database.Queries
+}
+
+func NewDBPersonalTokens(db *database.Queries) *DBPersonalTokens {
+	return &DBPersonalTokens{Db: db}
+}
+
+func (p *DBPersonalTokens) LookupPersonalAccessToken(ctx context.Context, token string) (*Claims, error) {
+	row, err := p.Db.GetPersonalAccessTokenByHash(ctx, HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: unknown personal access token", ErrTokenSignature)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if row.ExpiresAt.Valid && !time.Now().Before(row.ExpiresAt.Time) {
+		return nil, fmt.Errorf("%w: personal access token expired", ErrTokenExpired)
+	}
+
+	if err := p.Db.TouchPersonalAccessToken(ctx, row.ID); err != nil {
+		log.Printf("recording personal access token use: %v", err)
+	}
+
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:      row.ID.String(),
+			Subject: row.UserID.String(),
+		},
+		Role:     row.Role,
+		Scope:    strings.Join(row.Scopes, " "),
+		UserID:   row.UserID,
+		Personal: true,
+	}
+	if row.ExpiresAt.Valid {
+		claims.ExpiresAt = jwt.NewNumericDate(row.ExpiresAt.Time)
+	}
+	return claims, nil
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type fakePersonalTokens map[string]*Claims
+
+func (f fakePersonalTokens) LookupPersonalAccessToken(ctx context.Context, token string) (*Claims, error) {
+	claims, ok := f[HashToken(token)]
+	if !ok {
+		return nil, ErrTokenSignature
+	}
+	return claims, nil
+}
+
+func TestMakePersonalAccessToken(t *testing.T) {
+	token, prefix, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken() error = %v", err)
+	}
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("IsPersonalAccessToken(%q) = false, want true", token)
+	}
+	if !strings.HasPrefix(token, prefix) || len(prefix) >= len(token) {
+		t.Errorf("display prefix %q is not a strict prefix of the token", prefix)
+	}
+	if HashToken(token) != HashToken(token) || HashToken(token) == token {
+		t.Errorf("HashToken() should be deterministic and differ from the token")
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	userID := uuid.New()
+	pat, _, _ := MakePersonalAccessToken()
+	authenticator := &Authenticator{
+		Secret: "secret",
+		PersonalTokens: fakePersonalTokens{
+			HashToken(pat): {UserID: userID, Scope: ScopeChirpsRead, Personal: true},
+		},
+	}
+	sessionToken, _ := MakeJWT(userID, "secret", TokenOptions{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		token      string
+		scope      string
+		wantStatus int
+	}{
+		{name: "Session token is unrestricted", token: sessionToken, scope: ScopeChirpsWrite, wantStatus: http.StatusOK},
+		{name: "PAT with granted scope", token: pat, scope: ScopeChirpsRead, wantStatus: http.StatusOK},
+		{name: "PAT without granted scope", token: pat, scope: ScopeChirpsWrite, wantStatus: http.StatusForbidden},
+		{name: "Unknown PAT", token: PersonalTokenPrefix + "nope", scope: ScopeChirpsRead, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			authenticator.RequireScope(tt.scope, next).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import "net/http"
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles are ordered: an admin can do anything a moderator can, and a
+// moderator anything a user can.
+var roleRank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// HasRole reports whether the token holder has at least the given role.
+// Tokens issued before roles existed carry no role and count as RoleUser.
+// Delegated credentials never act with the owner's role: scopes are all
+// they grant, so a moderator's personal access token cannot moderate.
+func (c *Claims) HasRole(role string) bool {
+	if c.Delegated() {
+		return false
+	}
+	held := c.Role
+	if held == "" {
+		held = RoleUser
+	}
+	return roleRank[held] >= roleRank[role]
+}
+
+// RequireRole authenticates like RequireAuth and additionally answers 403
+// unless the caller holds at least role with their own login.
+func (a *Authenticator) RequireRole(role string, next http.Handler) http.Handler {
+	return a.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := UserFromContext(r.Context())
+		if claims.Delegated() || !claims.HasRole(role) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
@@ -7,29 +7,235 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countRepliesForChirps = `-- name: CountRepliesForChirps :many
+SELECT reply_to_id, COUNT(*) AS reply_count FROM chirps
+WHERE reply_to_id = ANY($1::uuid[]) AND deleted_at IS NULL
+GROUP BY reply_to_id
+`
+
+type CountRepliesForChirpsRow struct {
+	ReplyToID  uuid.NullUUID
+	ReplyCount int64
+}
+
+func (q *Queries) CountRepliesForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]CountRepliesForChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, countRepliesForChirps, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountRepliesForChirpsRow
+	for rows.Next() {
+		var i CountRepliesForChirpsRow
+		if err := rows.Scan(
+			&i.ReplyToID,
+			&i.ReplyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, repost_of_id)
 VALUES (
     gen_random_uuid (),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body       string
+	UserID     uuid.UUID
+	ReplyToID  uuid.NullUUID
+	RepostOfID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ReplyToID,
+		arg.RepostOfID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.RepostOfID,
+		&i.FannedOutAt,
+	)
+	return i, err
+}
+
+const createRechirp = `-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, repost_of_id)
+VALUES (
+    gen_random_uuid (),
+    NOW(),
+    NOW(),
+    '',
+    $1,
+    $2
+)
+ON CONFLICT (user_id, repost_of_id) WHERE body = '' AND deleted_at IS NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at
+`
+
+type CreateRechirpParams struct {
+	UserID     uuid.UUID
+	RepostOfID uuid.NullUUID
+}
+
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRechirp, arg.UserID, arg.RepostOfID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.RepostOfID,
+		&i.FannedOutAt,
+	)
+	return i, err
+}
+
+const getChirp = `-- name: GetChirp :one
+SELECT id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at FROM chirps WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.RepostOfID,
+		&i.FannedOutAt,
+	)
+	return i, err
+}
+
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.id, parent.reply_to_id, 1 AS depth
+    FROM chirps parent JOIN chirps child ON child.reply_to_id = parent.id
+    WHERE child.id = $1
+    UNION ALL
+    SELECT c.id, c.reply_to_id, a.depth + 1
+    FROM chirps c JOIN ancestors a ON c.id = a.reply_to_id
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.deleted_at, chirps.reply_to_id, chirps.repost_of_id, chirps.fanned_out_at FROM chirps JOIN ancestors ON chirps.id = ancestors.id
+WHERE chirps.deleted_at IS NULL
+ORDER BY ancestors.depth DESC
+`
+
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.RepostOfID,
+			&i.FannedOutAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpDescendantIDs = `-- name: GetChirpDescendantIDs :many
+WITH RECURSIVE descendants AS (
+    SELECT id FROM chirps WHERE reply_to_id = $1::uuid
+    UNION ALL
+    SELECT c.id FROM chirps c JOIN descendants d ON c.reply_to_id = d.id
+)
+SELECT id FROM descendants
+`
+
+func (q *Queries) GetChirpDescendantIDs(ctx context.Context, chirpID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendantIDs, chirpID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpForUpdate = `-- name: GetChirpForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at FROM chirps WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+`
+
+func (q *Queries) GetChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForUpdate, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,21 +243,75 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.RepostOfID,
+		&i.FannedOutAt,
 	)
 	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1
+const getChirpReplies = `-- name: GetChirpReplies :many
+WITH RECURSIVE descendants AS (
+    SELECT id FROM chirps WHERE reply_to_id = $1::uuid
+    UNION ALL
+    SELECT c.id FROM chirps c JOIN descendants d ON c.reply_to_id = d.id
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.deleted_at, chirps.reply_to_id, chirps.repost_of_id, chirps.fanned_out_at FROM chirps JOIN descendants ON chirps.id = descendants.id
+WHERE chirps.deleted_at IS NULL
+  AND ($2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($2::timestamp, $3::uuid))
+ORDER BY chirps.created_at, chirps.id
+LIMIT $4
 `
 
-func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, id)
-	return err
+type GetChirpRepliesParams struct {
+	ChirpID        uuid.UUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	MaxResults     int32
+}
+
+func (q *Queries) GetChirpReplies(ctx context.Context, arg GetChirpRepliesParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpReplies,
+		arg.ChirpID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.RepostOfID,
+			&i.FannedOutAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at
+SELECT id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at FROM chirps WHERE deleted_at IS NULL ORDER BY created_at
 `
 
 func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
@@ -69,6 +329,47 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.RepostOfID,
+			&i.FannedOutAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at FROM chirps WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.RepostOfID,
+			&i.FannedOutAt,
 		); err != nil {
 			return nil, err
 		}
@@ -82,3 +383,277 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const getChirpsDeletedWithUser = `-- name: GetChirpsDeletedWithUser :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at FROM chirps
+WHERE deleted_at IS NULL
+  AND (user_id = $1
+    OR (body = '' AND repost_of_id IN (SELECT id FROM chirps WHERE user_id = $1)))
+`
+
+func (q *Queries) GetChirpsDeletedWithUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsDeletedWithUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.RepostOfID,
+			&i.FannedOutAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsForUser = `-- name: GetChirpsForUser :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at FROM chirps WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at
+`
+
+func (q *Queries) GetChirpsForUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.RepostOfID,
+			&i.FannedOutAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeletedChirp = `-- name: GetDeletedChirp :one
+SELECT id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at FROM chirps WHERE id = $1 AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) GetDeletedChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.RepostOfID,
+		&i.FannedOutAt,
+	)
+	return i, err
+}
+
+const getTimeline = `-- name: GetTimeline :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at FROM chirps
+WHERE (user_id = $1::uuid
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1::uuid))
+  AND deleted_at IS NULL
+  AND ($2::timestamp IS NULL
+    OR (created_at, id) < ($2::timestamp, $3::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetTimelineParams struct {
+	UserID          uuid.UUID
+	BeforeCreatedAt sql.NullTime
+	BeforeID        uuid.NullUUID
+	MaxResults      int32
+}
+
+func (q *Queries) GetTimeline(ctx context.Context, arg GetTimelineParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getTimeline,
+		arg.UserID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.RepostOfID,
+			&i.FannedOutAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps WHERE deleted_at < $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :many
+UPDATE chirps SET deleted_at = NULL
+WHERE id = $1
+   OR (repost_of_id = $1 AND body = ''
+       AND deleted_at = (SELECT deleted_at FROM chirps WHERE id = $1))
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at
+`
+
+func (q *Queries) RestoreChirp(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, restoreChirp, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.RepostOfID,
+			&i.FannedOutAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :many
+UPDATE chirps SET deleted_at = NOW()
+WHERE deleted_at IS NULL AND (id = $1 OR (repost_of_id = $1 AND body = ''))
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at
+`
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, softDeleteChirp, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.RepostOfID,
+			&i.FannedOutAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, reply_to_id, repost_of_id, fanned_out_at
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.RepostOfID,
+		&i.FannedOutAt,
+	)
+	return i, err
+}
@@ -6,17 +6,143 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Chirp struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Body        string
+	UserID      uuid.UUID
+	DeletedAt   sql.NullTime
+	ReplyToID   uuid.NullUUID
+	RepostOfID  uuid.NullUUID
+	FannedOutAt sql.NullTime
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Handle    string
+	CreatedAt time.Time
+}
+
+type ChirpRevision struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	ChirpID   uuid.UUID
 	Body      string
+}
+
+type DeniedAccessToken struct {
+	Jti       string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type DeniedUser struct {
+	UserID       uuid.UUID
+	DeniedBefore time.Time
+	ExpiresAt    time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type ModerationAction struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	ModeratorID   uuid.NullUUID
+	Action        string
+	ChirpID       uuid.UUID
+	ChirpAuthorID uuid.UUID
+	ChirpBody     string
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
 	UserID    uuid.UUID
+	UpdatedAt time.Time
+	Mentions  bool
+	Replies   bool
+	Likes     bool
+	Follows   bool
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scopes       []string
+}
+
+type OauthRefreshToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ClientID  string
+	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+	Scope     string
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Name        string
+	TokenHash   string
+	TokenPrefix string
+	Scopes      []string
+	ExpiresAt   sql.NullTime
+	LastUsedAt  sql.NullTime
 }
 
 type RefreshToken struct {
@@ -28,10 +154,71 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type TimelineEntry struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type TrendComputation struct {
+	WindowName string
+	ComputedAt time.Time
+}
+
+type TrendSnapshot struct {
+	WindowName string
+	ComputedAt time.Time
+	Rank       int32
+	Tag        string
+	Score      float64
+	Uses       int64
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
+	Role           string
+	Username       string
+	DisplayName    string
+	Bio            string
+	AvatarUrl      string
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Issuer    string
+	Subject   string
+	Email     string
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	Events    []string
+	AllUsers  bool
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	WebhookID      uuid.UUID
+	Event          string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
 }
@@ -7,29 +7,33 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username)
 VALUES (
     gen_random_uuid (),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email, hashed_password
+RETURNING id, created_at, updated_at, email, hashed_password, role, username, display_name, bio, avatar_url
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Username       string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Username)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -37,10 +41,24 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.Role,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
 const deleteUsers = `-- name: DeleteUsers :exec
 DELETE FROM users
 `
@@ -51,7 +69,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const emailLookup = `-- name: EmailLookup :one
-SELECT id, created_at, updated_at, email, hashed_password FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, role, username, display_name, bio, avatar_url FROM users WHERE email = $1
 `
 
 func (q *Queries) EmailLookup(ctx context.Context, email string) (User, error) {
@@ -63,10 +81,147 @@ func (q *Queries) EmailLookup(ctx context.Context, email string) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.Role,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+	)
+	return i, err
+}
+
+const getAuthorsByIDs = `-- name: GetAuthorsByIDs :many
+SELECT id, username, display_name FROM users WHERE id = ANY($1::uuid[])
+`
+
+type GetAuthorsByIDsRow struct {
+	ID          uuid.UUID
+	Username    string
+	DisplayName string
+}
+
+func (q *Queries) GetAuthorsByIDs(ctx context.Context, ids []uuid.UUID) ([]GetAuthorsByIDsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAuthorsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAuthorsByIDsRow
+	for rows.Next() {
+		var i GetAuthorsByIDsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.DisplayName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, created_at, updated_at, email, hashed_password, role, username, display_name, bio, avatar_url FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, created_at, updated_at, email, hashed_password, role, username, display_name, bio, avatar_url FROM users WHERE lower(username) = lower($1::text)
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
 	)
 	return i, err
 }
 
+const getUsersByUsernames = `-- name: GetUsersByUsernames :many
+SELECT id, username FROM users WHERE lower(username) = ANY($1::text[])
+`
+
+type GetUsersByUsernamesRow struct {
+	ID       uuid.UUID
+	Username string
+}
+
+func (q *Queries) GetUsersByUsernames(ctx context.Context, usernames []string) ([]GetUsersByUsernamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByUsernames, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByUsernamesRow
+	for rows.Next() {
+		var i GetUsersByUsernamesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserRole = `-- name: SetUserRole :exec
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) error {
+	_, err := q.db.ExecContext(ctx, setUserRole, arg.Role, arg.ID)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :exec
 UPDATE users
 SET email = $1, hashed_password= $2
@@ -83,3 +238,46 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
 	_, err := q.db.ExecContext(ctx, updateUser, arg.Email, arg.HashedPassword, arg.ID)
 	return err
 }
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET username = COALESCE($1, username),
+    display_name = COALESCE($2, display_name),
+    bio = COALESCE($3, bio),
+    avatar_url = COALESCE($4, avatar_url),
+    updated_at = NOW()
+WHERE id = $5
+RETURNING id, created_at, updated_at, email, hashed_password, role, username, display_name, bio, avatar_url
+`
+
+type UpdateUserProfileParams struct {
+	Username    sql.NullString
+	DisplayName sql.NullString
+	Bio         sql.NullString
+	AvatarUrl   sql.NullString
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Username,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+	)
+	return i, err
+}
@@ -0,0 +1,117 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	TypeMention = "mention"
+	TypeHashtag = "hashtag"
+
+	// MaxMentionLength matches the longest username Chirpy accepts.
+	MaxMentionLength = 15
+	MaxHashtagLength = 100
+)
+
+// Entity is an @mention or #hashtag found in a chirp body. Start and End are
+// byte offsets into the body and include the leading sigil; Text omits it.
+type Entity struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Parse returns the mentions and hashtags in body in the order they appear.
+// A sigil only starts an entity at the beginning of the body or after a
+// character that cannot be part of a word, so email addresses and things
+// like "C#" are left alone.
+func Parse(body string) []Entity {
+	found := []Entity{}
+	prev := ' '
+	for i := 0; i < len(body); {
+		r, size := utf8.DecodeRuneInString(body[i:])
+		if (r == '@' || r == '#') && !isWordRune(prev) {
+			if entity, ok := parseAt(body, i); ok {
+				found = append(found, entity)
+				lastRune, _ := utf8.DecodeLastRuneInString(body[:entity.End])
+				prev = lastRune
+				i = entity.End
+				continue
+			}
+		}
+		prev = r
+		i += size
+	}
+	return found
+}
+
+func parseAt(body string, start int) (Entity, bool) {
+	sigil := body[start]
+	end := start + 1
+	hasLetter := false
+	for end < len(body) {
+		r, size := utf8.DecodeRuneInString(body[end:])
+		if sigil == '@' && !isUsernameRune(r) || sigil == '#' && !isWordRune(r) {
+			break
+		}
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		end += size
+	}
+
+	text := body[start+1 : end]
+	if text == "" {
+		return Entity{}, false
+	}
+	if sigil == '@' {
+		if len(text) > MaxMentionLength {
+			return Entity{}, false
+		}
+		return Entity{Type: TypeMention, Text: text, Start: start, End: end}, true
+	}
+	if !hasLetter || len(text) > MaxHashtagLength {
+		return Entity{}, false
+	}
+	return Entity{Type: TypeHashtag, Text: text, Start: start, End: end}, true
+}
+
+func isUsernameRune(r rune) bool {
+	return r < utf8.RuneSelf && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// NormalizeTag folds a hashtag to the form it is stored and looked up by.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// Mentions returns the distinct mentioned handles, lowercased.
+func Mentions(found []Entity) []string {
+	return distinct(found, TypeMention)
+}
+
+// Hashtags returns the distinct hashtags, normalized.
+func Hashtags(found []Entity) []string {
+	return distinct(found, TypeHashtag)
+}
+
+func distinct(found []Entity, entityType string) []string {
+	seen := map[string]bool{}
+	values := []string{}
+	for _, entity := range found {
+		if entity.Type != entityType {
+			continue
+		}
+		value := strings.ToLower(entity.Text)
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
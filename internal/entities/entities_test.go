@@ -0,0 +1,66 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Entity
+	}{
+		{name: "No entities", body: "just chirping", want: []Entity{}},
+		{
+			name: "Mention and hashtag",
+			body: "hey @alice_1 look at #Go",
+			want: []Entity{
+				{Type: TypeMention, Text: "alice_1", Start: 4, End: 12},
+				{Type: TypeHashtag, Text: "Go", Start: 21, End: 24},
+			},
+		},
+		{
+			name: "Trailing punctuation is not part of the entity",
+			body: "(@bob), #fun!",
+			want: []Entity{
+				{Type: TypeMention, Text: "bob", Start: 1, End: 5},
+				{Type: TypeHashtag, Text: "fun", Start: 8, End: 12},
+			},
+		},
+		{name: "Email address", body: "mail me@example.com", want: []Entity{}},
+		{name: "Sigil inside a word", body: "C# and a#b", want: []Entity{}},
+		{name: "Numeric hashtag", body: "#2024", want: []Entity{}},
+		{name: "Bare sigils", body: "@ # @@", want: []Entity{}},
+		{name: "Handle too long", body: "@abcdefghijklmnop", want: []Entity{}},
+		{
+			name: "Unicode hashtag offsets are in bytes",
+			body: "¡#café",
+			want: []Entity{{Type: TypeHashtag, Text: "café", Start: 2, End: 8}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.body, got, tt.want)
+			}
+			for _, entity := range got {
+				if tt.body[entity.Start+1:entity.End] != entity.Text {
+					t.Errorf("offsets [%d:%d] do not cover %q", entity.Start, entity.End, entity.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	found := Parse("#Go #go @Ann @ann #rust")
+	if got, want := Hashtags(found), []string{"go", "rust"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Hashtags() = %v, want %v", got, want)
+	}
+	if got, want := Mentions(found), []string{"ann"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Mentions() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	AuthorizationCodeTTL = 10 * time.Minute
+	RefreshTokenTTL      = 60 * 24 * time.Hour
+)
+
+// Error codes from RFC 6749 section 4.1.2.1 and 5.2.
+const (
+	ErrInvalidRequest       = "invalid_request"
+	ErrInvalidClient        = "invalid_client"
+	ErrInvalidGrant         = "invalid_grant"
+	ErrUnauthorizedClient   = "unauthorized_client"
+	ErrUnsupportedGrantType = "unsupported_grant_type"
+	ErrUnsupportedResponse  = "unsupported_response_type"
+	ErrInvalidScope         = "invalid_scope"
+	ErrAccessDenied         = "access_denied"
+	ErrServerError          = "server_error"
+)
+
+var ErrScopeNotAllowed = errors.New("requested scope is not allowed for this client")
+
+// TokenResponse is the successful token endpoint response body.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// IntrospectionResponse is the RFC 7662 token introspection response. Only
+// Active is set for inactive tokens.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func NewClientID() (string, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	return "chirpy_client_" + id, nil
+}
+
+func NewClientSecret() (string, error) {
+	return randomHex(32)
+}
+
+// VerifyPKCE checks an RFC 7636 code verifier against the S256 challenge
+// recorded with the authorization code.
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// NormalizeScope validates a space-delimited scope request against the
+// scopes a client was registered with. An empty request grants all of them.
+func NormalizeScope(requested string, allowed []string) (string, error) {
+	fields := strings.Fields(requested)
+	if len(fields) == 0 {
+		return strings.Join(allowed, " "), nil
+	}
+
+	granted := []string{}
+	for _, scope := range fields {
+		if !slices.Contains(allowed, scope) {
+			return "", ErrScopeNotAllowed
+		}
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return strings.Join(granted, " "), nil
+}
+
+// ValidRedirectURI reports whether a client may register redirectURI: an
+// absolute https URI without a fragment, or http for a native app listening
+// on the loopback interface (RFC 8252 section 7.3). Other schemes, such as
+// javascript: and data:, would run in the browser that gets redirected.
+func ValidRedirectURI(redirectURI string) bool {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || parsed.Fragment != "" || parsed.Host == "" {
+		return false
+	}
+	switch parsed.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := parsed.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+// RedirectURL appends params to a registered redirect URI, keeping any query
+// it already has.
+func RedirectURL(redirectURI string, params url.Values) string {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := parsed.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// ClientCredentials reads client authentication from HTTP Basic auth or,
+// failing that, from the client_id and client_secret form fields.
+func ClientCredentials(r *http.Request) (clientID, clientSecret string) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+		return id, secret
+	}
+	return r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+}
+
+func WriteError(w http.ResponseWriter, status int, code, description string) {
+	if code == ErrInvalidClient {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description,omitempty"`
+	}{code, description})
+}
+
+func WriteJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(v)
+}
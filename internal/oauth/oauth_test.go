@@ -0,0 +1,113 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier := strings.Repeat("a", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{name: "Matching verifier", verifier: verifier, challenge: challenge, want: true},
+		{name: "Wrong verifier", verifier: strings.Repeat("b", 43), challenge: challenge, want: false},
+		{name: "Verifier too short", verifier: "short", challenge: challenge, want: false},
+		{name: "Plain challenge is rejected", verifier: verifier, challenge: verifier, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("VerifyPKCE() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeScope(t *testing.T) {
+	allowed := []string{"chirps:read", "chirps:write"}
+
+	tests := []struct {
+		name      string
+		requested string
+		want      string
+		wantErr   error
+	}{
+		{name: "Empty request grants all", requested: "", want: "chirps:read chirps:write"},
+		{name: "Subset", requested: "chirps:read", want: "chirps:read"},
+		{name: "Duplicates collapse", requested: "chirps:read  chirps:read", want: "chirps:read"},
+		{name: "Scope not registered", requested: "chirps:read profile:write", wantErr: ErrScopeNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeScope(tt.requested, allowed)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeScope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeScope() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidRedirectURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want bool
+	}{
+		{uri: "https://app.example/cb", want: true},
+		{uri: "https://app.example/cb?keep=1", want: true},
+		{uri: "http://127.0.0.1:8400/cb", want: true},
+		{uri: "http://[::1]:8400/cb", want: true},
+		{uri: "http://localhost/cb", want: true},
+		{uri: "http://app.example/cb", want: false},
+		{uri: "https://app.example/cb#frag", want: false},
+		{uri: "javascript:alert(1)", want: false},
+		{uri: "data:text/html,<script>alert(1)</script>", want: false},
+		{uri: "com.example.app:/cb", want: false},
+		{uri: "/cb", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidRedirectURI(tt.uri); got != tt.want {
+			t.Errorf("ValidRedirectURI(%q) = %v, want %v", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestRedirectURL(t *testing.T) {
+	got := RedirectURL("https://app.example/cb?keep=1", url.Values{"code": {"abc"}, "state": {""}})
+	parsed, _ := url.Parse(got)
+	query := parsed.Query()
+	if query.Get("keep") != "1" || query.Get("code") != "abc" || query.Has("state") {
+		t.Errorf("RedirectURL() = %q", got)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	req := httptest.NewRequest("POST", "/oauth/token", strings.NewReader("client_id=form-id&client_secret=form-secret"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id, secret := ClientCredentials(req); id != "form-id" || secret != "form-secret" {
+		t.Errorf("ClientCredentials() from form = %q, %q", id, secret)
+	}
+
+	req = httptest.NewRequest("POST", "/oauth/token", nil)
+	req.SetBasicAuth("basic-id", "basic%20secret")
+	if id, secret := ClientCredentials(req); id != "basic-id" || secret != "basic secret" {
+		t.Errorf("ClientCredentials() from basic auth = %q, %q", id, secret)
+	}
+}
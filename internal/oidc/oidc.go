@@ -0,0 +1,277 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// Identity is what Chirpy needs to know about a user authenticated by an
+// external identity provider. Issuer and Subject together identify the user.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// IdentityProvider is the pluggable part of the login flow: something that
+// can send the user off to authenticate and turn the code it returns into an
+// Identity.
+type IdentityProvider interface {
+	AuthCodeURL(state, nonce, codeChallenge string) string
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error)
+}
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an IdentityProvider backed by an OpenID Connect issuer using
+// the authorization code flow with PKCE.
+type Provider struct {
+	config   Config
+	metadata metadata
+	client   *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	keysFetch time.Time
+}
+
+// NewProvider discovers the issuer's endpoints from its
+// /.well-known/openid-configuration document.
+func NewProvider(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email"}
+	}
+
+	discoveryURL := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	var meta metadata
+	if err := getJSON(ctx, client, discoveryURL, &meta); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if meta.Issuer != config.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match configured %q", meta.Issuer, config.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+
+	return &Provider{config: config, metadata: meta, client: client}, nil
+}
+
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("oidc token exchange: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("oidc token exchange: unexpected status %d", res.StatusCode)
+	}
+
+	var tokenRes struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tokenRes); err != nil {
+		return Identity{}, fmt.Errorf("oidc token exchange: %w", err)
+	}
+	if tokenRes.IDToken == "" {
+		return Identity{}, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+
+	return p.VerifyIDToken(ctx, tokenRes.IDToken, nonce)
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// VerifyIDToken checks the token's RS256 signature against the issuer's
+// published keys along with its issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (Identity, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	}
+
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if claims.Subject == "" {
+		return Identity{}, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	if claims.Nonce != nonce {
+		return Identity{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return Identity{
+		Issuer:        p.config.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+// publicKey returns the signing key with the given key ID, refetching the
+// JWKS when the key is unknown so issuer key rotation is picked up. Refetches
+// are rate limited to avoid hammering the issuer with bogus key IDs.
+func (p *Provider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetch) < 10*time.Second {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	p.keysFetch = time.Now()
+	if err := getJSON(ctx, p.client, p.metadata.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("fetching jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, nErr := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, eErr := base64.RawURLEncoding.DecodeString(jwk.E)
+		if nErr != nil || eErr != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = keys
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// NewPKCE returns a random code verifier and its S256 code challenge.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", endpoint, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,165 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeIssuer is a minimal OpenID Connect provider: discovery, JWKS and a
+// token endpoint that checks PKCE and returns a signed ID token.
+type fakeIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	clientID  string
+	challenge string
+	nonce     string
+	audience  string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	f := &fakeIssuer{key: key, clientID: "chirpy"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.server.URL,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != f.challenge {
+			w.WriteHeader(400)
+			return
+		}
+		audience := f.audience
+		if audience == "" {
+			audience = f.clientID
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": f.sign(t, audience)})
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeIssuer) sign(t *testing.T, audience string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, idTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    f.server.URL,
+			Subject:   "external-user-1",
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Nonce:         f.nonce,
+		Email:         "user@example.com",
+		EmailVerified: true,
+	})
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(f.key)
+	if err != nil {
+		t.Fatalf("signing id token: %v", err)
+	}
+	return signed
+}
+
+func TestProviderLoginFlow(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	ctx := context.Background()
+
+	provider, err := NewProvider(ctx, Config{
+		Issuer:      issuer.server.URL,
+		ClientID:    issuer.clientID,
+		RedirectURL: "http://chirpy.test/api/auth/oidc/callback",
+	}, issuer.server.Client())
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+
+	state, challenge, err := NewLoginState()
+	if err != nil {
+		t.Fatalf("NewLoginState() error = %v", err)
+	}
+	authURL, err := url.Parse(provider.AuthCodeURL(state.State, state.Nonce, challenge))
+	if err != nil {
+		t.Fatalf("parsing AuthCodeURL: %v", err)
+	}
+	if got := authURL.Query().Get("code_challenge_method"); got != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", got)
+	}
+	issuer.challenge = authURL.Query().Get("code_challenge")
+	issuer.nonce = authURL.Query().Get("nonce")
+
+	identity, err := provider.Exchange(ctx, "good-code", state.CodeVerifier, state.Nonce)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	want := Identity{Issuer: issuer.server.URL, Subject: "external-user-1", Email: "user@example.com", EmailVerified: true}
+	if identity != want {
+		t.Errorf("Exchange() = %+v, want %+v", identity, want)
+	}
+
+	if _, err := provider.Exchange(ctx, "good-code", "wrong-verifier", state.Nonce); err == nil {
+		t.Errorf("Exchange() with wrong PKCE verifier succeeded")
+	}
+	if _, err := provider.Exchange(ctx, "good-code", state.CodeVerifier, "other-nonce"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("Exchange() with wrong nonce error = %v, want %v", err, ErrInvalidIDToken)
+	}
+
+	issuer.audience = "someone-else"
+	if _, err := provider.Exchange(ctx, "good-code", state.CodeVerifier, state.Nonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("Exchange() with wrong audience error = %v, want %v", err, ErrInvalidIDToken)
+	}
+}
+
+func TestLoginStateRoundTrip(t *testing.T) {
+	state, _, err := NewLoginState()
+	if err != nil {
+		t.Fatalf("NewLoginState() error = %v", err)
+	}
+	sealed, err := SealLoginState(state, "secret")
+	if err != nil {
+		t.Fatalf("SealLoginState() error = %v", err)
+	}
+
+	opened, err := OpenLoginState(sealed, "secret")
+	if err != nil || opened != state {
+		t.Errorf("OpenLoginState() = %+v, %v, want %+v", opened, err, state)
+	}
+	if _, err := OpenLoginState(sealed, "other-secret"); !errors.Is(err, ErrInvalidLoginState) {
+		t.Errorf("OpenLoginState() with wrong secret error = %v, want %v", err, ErrInvalidLoginState)
+	}
+}
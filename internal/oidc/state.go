@@ -0,0 +1,72 @@
+package oidc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidLoginState = errors.New("invalid login state")
+
+const loginStateTTL = 10 * time.Minute
+
+// LoginState is carried from the start of a login to its callback. It is
+// sealed into a signed, short-lived cookie so any server instance can finish
+// a login another instance started.
+type LoginState struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+type loginStateClaims struct {
+	jwt.RegisteredClaims
+	LoginState
+}
+
+// NewLoginState generates a fresh state, nonce and PKCE verifier and returns
+// the matching code challenge for the authorization request.
+func NewLoginState() (LoginState, string, error) {
+	state, err := randomString(24)
+	if err != nil {
+		return LoginState{}, "", err
+	}
+	nonce, err := randomString(24)
+	if err != nil {
+		return LoginState{}, "", err
+	}
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		return LoginState{}, "", err
+	}
+	return LoginState{State: state, Nonce: nonce, CodeVerifier: verifier}, challenge, nil
+}
+
+func SealLoginState(state LoginState, secret string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, loginStateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{"chirpy-oidc-login"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(loginStateTTL)),
+		},
+		LoginState: state,
+	})
+	return token.SignedString([]byte(secret))
+}
+
+func OpenLoginState(sealed, secret string) (LoginState, error) {
+	claims := &loginStateClaims{}
+	_, err := jwt.ParseWithClaims(sealed, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience("chirpy-oidc-login"),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return LoginState{}, errors.Join(ErrInvalidLoginState, err)
+	}
+	return claims.LoginState, nil
+}
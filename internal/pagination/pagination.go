@@ -0,0 +1,104 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	ErrInvalidLimit  = errors.New("invalid pagination limit")
+)
+
+// Cursor marks a position in a list ordered by (created_at, id). Lists ranked
+// by relevance order by (rank, created_at, id) instead and carry the rank as
+// well. Encoded cursors are opaque to clients.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	Rank      *float64
+}
+
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	if c.Rank != nil {
+		raw += "|" + strconv.FormatFloat(*c.Rank, 'g', -1, 64)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func Decode(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 2 && len(parts) != 3 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	createdAt, id := parts[0], parts[1]
+	parsedTime, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	cursor := Cursor{CreatedAt: parsedTime, ID: parsedID}
+	if len(parts) == 3 {
+		rank, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return Cursor{}, ErrInvalidCursor
+		}
+		cursor.Rank = &rank
+	}
+	return cursor, nil
+}
+
+// Page is a request for one page of results: up to Limit items following
+// After, or from the start of the list when After is nil.
+type Page struct {
+	After *Cursor
+	Limit int
+}
+
+// Parse reads the cursor and limit query parameters.
+func Parse(query url.Values) (Page, error) {
+	page := Page{Limit: DefaultLimit}
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > MaxLimit {
+			return Page{}, ErrInvalidLimit
+		}
+		page.Limit = parsed
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := Decode(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		page.After = &after
+	}
+	return page, nil
+}
+
+// Next returns the cursor for the page after one ending at last, or "" when
+// a short page shows there is nothing more to fetch.
+func (p Page) Next(count int, last Cursor) string {
+	if count < p.Limit {
+		return ""
+	}
+	return last.Encode()
+}
@@ -0,0 +1,91 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC), ID: uuid.New()}
+	got, err := Decode(want.Encode())
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("Decode(Encode()) = %+v, want %+v", got, want)
+	}
+}
+
+func TestRankedCursorRoundTrip(t *testing.T) {
+	rank := 0.0607927
+	want := Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), ID: uuid.New(), Rank: &rank}
+	got, err := Decode(want.Encode())
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.Rank == nil || *got.Rank != rank {
+		t.Errorf("Decode(Encode()).Rank = %v, want %v", got.Rank, rank)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("Decode(Encode()) = %+v, want %+v", got, want)
+	}
+
+	plain, err := Decode(Cursor{CreatedAt: want.CreatedAt, ID: want.ID}.Encode())
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if plain.Rank != nil {
+		t.Errorf("Decode() of an unranked cursor has Rank %v", *plain.Rank)
+	}
+}
+
+func TestParse(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Now(), ID: uuid.New()}
+
+	tests := []struct {
+		name      string
+		query     url.Values
+		wantLimit int
+		wantAfter bool
+		wantErr   error
+	}{
+		{name: "Defaults", query: url.Values{}, wantLimit: DefaultLimit},
+		{name: "Limit and cursor", query: url.Values{"limit": {"5"}, "cursor": {cursor.Encode()}}, wantLimit: 5, wantAfter: true},
+		{name: "Limit too large", query: url.Values{"limit": {"1000"}}, wantErr: ErrInvalidLimit},
+		{name: "Limit not a number", query: url.Values{"limit": {"ten"}}, wantErr: ErrInvalidLimit},
+		{name: "Garbage cursor", query: url.Values{"cursor": {"not-a-cursor"}}, wantErr: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := Parse(tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if page.Limit != tt.wantLimit {
+				t.Errorf("Limit = %d, want %d", page.Limit, tt.wantLimit)
+			}
+			if (page.After != nil) != tt.wantAfter {
+				t.Errorf("After = %v, wantAfter %v", page.After, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	last := Cursor{CreatedAt: time.Now(), ID: uuid.New()}
+	page := Page{Limit: 2}
+	if got := page.Next(1, last); got != "" {
+		t.Errorf("Next() on a short page = %q, want empty", got)
+	}
+	if got := page.Next(2, last); got != last.Encode() {
+		t.Errorf("Next() on a full page = %q, want %q", got, last.Encode())
+	}
+}
@@ -0,0 +1,199 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultSendBuffer is how many outgoing messages a connection may have
+	// queued before it is disconnected as a slow consumer.
+	DefaultSendBuffer = 64
+
+	PingInterval   = 30 * time.Second
+	PongWait       = 60 * time.Second
+	WriteWait      = 10 * time.Second
+	MaxMessageSize = 4096
+)
+
+// ClientMessage is a request from the client, such as
+// {"type": "subscribe", "topic": "thread", "chirp_id": "..."}.
+type ClientMessage struct {
+	Type    string     `json:"type"`
+	Topic   string     `json:"topic"`
+	ChirpID *uuid.UUID `json:"chirp_id,omitempty"`
+}
+
+// ServerMessage is anything sent to the client: acknowledgements, errors
+// and the events of subscribed topics.
+type ServerMessage struct {
+	Type    string          `json:"type"`
+	Topic   string          `json:"topic,omitempty"`
+	ChirpID *uuid.UUID      `json:"chirp_id,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// Conn owns one WebSocket connection. Messages go out through a bounded
+// queue drained by WritePump, so a client that stops reading can never
+// block the code producing its events.
+type Conn struct {
+	ws     *websocket.Conn
+	send   chan ServerMessage
+	done   chan struct{}
+	once   sync.Once
+	code   int
+	reason string
+}
+
+func NewConn(ws *websocket.Conn, sendBuffer int) *Conn {
+	return &Conn{ws: ws, send: make(chan ServerMessage, sendBuffer), done: make(chan struct{})}
+}
+
+// Done is closed once the connection is closing.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Send queues msg without blocking. A full queue means the client is not
+// keeping up, and it is disconnected.
+func (c *Conn) Send(msg ServerMessage) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		c.Close(websocket.CloseTryAgainLater, "slow consumer")
+		return false
+	}
+}
+
+// Close asks WritePump to send a close frame with code and reason and shut
+// the connection down. Only the first call has any effect.
+func (c *Conn) Close(code int, reason string) {
+	c.once.Do(func() {
+		c.code, c.reason = code, reason
+		close(c.done)
+	})
+}
+
+// WritePump writes queued messages and heartbeat pings until the
+// connection is closed. It must be the only writer on the connection.
+func (c *Conn) WritePump() {
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+	defer c.ws.Close()
+
+	for {
+		select {
+		case <-c.done:
+			message := websocket.FormatCloseMessage(c.code, c.reason)
+			c.ws.WriteControl(websocket.CloseMessage, message, time.Now().Add(WriteWait))
+			return
+		case msg := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(WriteWait))
+			if err := c.ws.WriteJSON(msg); err != nil {
+				c.Close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(WriteWait)); err != nil {
+				c.Close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+// ReadPump decodes client messages onto messages until the connection
+// fails, the client goes quiet for longer than PongWait, or it closes. It
+// closes messages when it returns.
+func (c *Conn) ReadPump(messages chan<- ClientMessage) {
+	defer close(messages)
+
+	c.ws.SetReadLimit(MaxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(PongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(PongWait))
+	})
+
+	for {
+		var msg ClientMessage
+		err := c.ws.ReadJSON(&msg)
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+			c.Send(ServerMessage{Type: "error", Error: "invalid message"})
+			continue
+		}
+		if err != nil {
+			c.Close(websocket.CloseNormalClosure, "")
+			return
+		}
+		select {
+		case messages <- msg:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Registry tracks open connections so they can be closed on shutdown.
+// Hijacked connections are invisible to http.Server.Shutdown.
+type Registry struct {
+	mu       sync.Mutex
+	conns    map[*Conn]struct{}
+	closing  bool
+	inflight sync.WaitGroup
+}
+
+func NewRegistry() *Registry {
+	return &Registry{conns: map[*Conn]struct{}{}}
+}
+
+// Run registers c and runs its WritePump, unregistering it once the
+// connection is shut.
+func (r *Registry) Run(c *Conn) {
+	r.mu.Lock()
+	r.conns[c] = struct{}{}
+	r.inflight.Add(1)
+	closing := r.closing
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, c)
+		r.mu.Unlock()
+		r.inflight.Done()
+	}()
+
+	if closing {
+		c.Close(websocket.CloseGoingAway, "server shutting down")
+	}
+	c.WritePump()
+}
+
+// Shutdown tells every connected client the server is going away.
+func (r *Registry) Shutdown() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closing = true
+	for c := range r.conns {
+		c.Close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// Wait blocks until every connection has sent its close frame.
+func (r *Registry) Wait() {
+	r.inflight.Wait()
+}
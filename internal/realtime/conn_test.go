@@ -0,0 +1,109 @@
+package realtime
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serve starts a server whose connections are handed to handle, and dials it.
+func serve(t *testing.T, handle func(*Conn)) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		handle(NewConn(ws, 2))
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return client
+}
+
+func readClose(t *testing.T, client *websocket.Conn) *websocket.CloseError {
+	t.Helper()
+	for {
+		_, _, err := client.ReadMessage()
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			return closeErr
+		}
+		if err != nil {
+			t.Fatalf("ReadMessage() error = %v, want a close frame", err)
+		}
+	}
+}
+
+func TestSlowConsumerDisconnected(t *testing.T) {
+	client := serve(t, func(c *Conn) {
+		// Nothing drains the queue until it has overflowed.
+		for i := 0; i < 3; i++ {
+			c.Send(ServerMessage{Type: "event"})
+		}
+		c.WritePump()
+	})
+
+	if closeErr := readClose(t, client); closeErr.Code != websocket.CloseTryAgainLater {
+		t.Errorf("close code = %d, want %d", closeErr.Code, websocket.CloseTryAgainLater)
+	}
+}
+
+func TestRegistryShutdown(t *testing.T) {
+	registry := NewRegistry()
+	ready := make(chan struct{})
+	client := serve(t, func(c *Conn) {
+		go registry.Run(c)
+		close(ready)
+	})
+
+	<-ready
+	registry.Shutdown()
+	if closeErr := readClose(t, client); closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("close code = %d, want %d", closeErr.Code, websocket.CloseGoingAway)
+	}
+	registry.Wait()
+}
+
+func TestInvalidMessageKeepsConnection(t *testing.T) {
+	received := make(chan ClientMessage, 1)
+	client := serve(t, func(c *Conn) {
+		go c.WritePump()
+		messages := make(chan ClientMessage)
+		go c.ReadPump(messages)
+		for msg := range messages {
+			received <- msg
+		}
+	})
+
+	client.WriteMessage(websocket.TextMessage, []byte("{not json"))
+	var reply ServerMessage
+	if err := client.ReadJSON(&reply); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+	if reply.Type != "error" {
+		t.Errorf("reply type = %q, want error", reply.Type)
+	}
+
+	client.WriteJSON(ClientMessage{Type: "subscribe", Topic: TopicTimeline})
+	select {
+	case msg := <-received:
+		if msg.Topic != TopicTimeline {
+			t.Errorf("received topic %q, want %q", msg.Topic, TopicTimeline)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message after an invalid one was not delivered")
+	}
+}
@@ -0,0 +1,101 @@
+package realtime
+
+import (
+	"encoding/json"
+
+	"github.com/BradDeA/chirpy.git/internal/stream"
+	"github.com/google/uuid"
+)
+
+const (
+	TopicTimeline      = "timeline"
+	TopicNotifications = "notifications"
+	TopicThread        = "thread"
+)
+
+// Match is one topic an event belongs to. ChirpID names the thread for
+// thread topics.
+type Match struct {
+	Topic   string
+	ChirpID *uuid.UUID
+}
+
+// Topics is what one connection has subscribed to. It belongs to the
+// connection's event loop and is not safe for concurrent use.
+type Topics struct {
+	// timeline holds the authors whose chirps belong on the timeline, or is
+	// nil when the timeline is not subscribed.
+	timeline      map[uuid.UUID]bool
+	notifications bool
+	// threads maps each subscribed thread to the chirps known to be in it,
+	// which grows as replies arrive.
+	threads map[uuid.UUID]map[uuid.UUID]bool
+}
+
+func NewTopics() *Topics {
+	return &Topics{threads: map[uuid.UUID]map[uuid.UUID]bool{}}
+}
+
+// SubscribeTimeline follows chirps by the given authors, replacing any
+// earlier timeline subscription.
+func (t *Topics) SubscribeTimeline(authors []uuid.UUID) {
+	t.timeline = map[uuid.UUID]bool{}
+	for _, author := range authors {
+		t.timeline[author] = true
+	}
+}
+
+func (t *Topics) UnsubscribeTimeline() {
+	t.timeline = nil
+}
+
+func (t *Topics) SetNotifications(subscribed bool) {
+	t.notifications = subscribed
+}
+
+func (t *Topics) Notifications() bool {
+	return t.notifications
+}
+
+// SubscribeThread follows root and the replies below it, given the ids of
+// the replies that already exist.
+func (t *Topics) SubscribeThread(root uuid.UUID, replies []uuid.UUID) {
+	members := map[uuid.UUID]bool{root: true}
+	for _, reply := range replies {
+		members[reply] = true
+	}
+	t.threads[root] = members
+}
+
+func (t *Topics) UnsubscribeThread(root uuid.UUID) {
+	delete(t.threads, root)
+}
+
+// MatchChirpEvent returns the subscribed topics a chirp event belongs to. A
+// new reply to a chirp in a followed thread joins that thread, so replies to
+// it are delivered too.
+func (t *Topics) MatchChirpEvent(event stream.Event) []Match {
+	var chirp struct {
+		Id          uuid.UUID  `json:"id"`
+		Reply_to_id *uuid.UUID `json:"reply_to_id"`
+	}
+	if err := json.Unmarshal(event.Data, &chirp); err != nil {
+		return nil
+	}
+
+	matches := []Match{}
+	if t.timeline[event.AuthorID] {
+		matches = append(matches, Match{Topic: TopicTimeline})
+	}
+	for root, members := range t.threads {
+		inThread := members[chirp.Id]
+		if event.Type == stream.EventChirpCreated && chirp.Reply_to_id != nil && members[*chirp.Reply_to_id] {
+			members[chirp.Id] = true
+			inThread = true
+		}
+		if inThread {
+			matches = append(matches, Match{Topic: TopicThread, ChirpID: &root})
+		}
+	}
+	return matches
+}
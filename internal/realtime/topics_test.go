@@ -0,0 +1,75 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/BradDeA/chirpy.git/internal/stream"
+	"github.com/google/uuid"
+)
+
+func chirpEvent(eventType string, author, id uuid.UUID, replyTo *uuid.UUID) stream.Event {
+	data, _ := json.Marshal(map[string]any{"id": id, "reply_to_id": replyTo})
+	return stream.Event{Type: eventType, AuthorID: author, Data: data}
+}
+
+func TestMatchTimeline(t *testing.T) {
+	followed, stranger := uuid.New(), uuid.New()
+	topics := NewTopics()
+
+	if got := topics.MatchChirpEvent(chirpEvent(stream.EventChirpCreated, followed, uuid.New(), nil)); len(got) != 0 {
+		t.Errorf("matched %v before subscribing", got)
+	}
+
+	topics.SubscribeTimeline([]uuid.UUID{followed})
+	if got := topics.MatchChirpEvent(chirpEvent(stream.EventChirpCreated, followed, uuid.New(), nil)); len(got) != 1 || got[0].Topic != TopicTimeline {
+		t.Errorf("followed author matched %v, want the timeline", got)
+	}
+	if got := topics.MatchChirpEvent(chirpEvent(stream.EventChirpCreated, stranger, uuid.New(), nil)); len(got) != 0 {
+		t.Errorf("stranger matched %v, want nothing", got)
+	}
+
+	topics.UnsubscribeTimeline()
+	if got := topics.MatchChirpEvent(chirpEvent(stream.EventChirpDeleted, followed, uuid.New(), nil)); len(got) != 0 {
+		t.Errorf("matched %v after unsubscribing", got)
+	}
+}
+
+func TestMatchThreadFollowsNewReplies(t *testing.T) {
+	author := uuid.New()
+	root, reply := uuid.New(), uuid.New()
+	topics := NewTopics()
+	topics.SubscribeThread(root, []uuid.UUID{reply})
+
+	nested := uuid.New()
+	tests := []struct {
+		name  string
+		event stream.Event
+		want  bool
+	}{
+		{name: "Reply to existing reply", event: chirpEvent(stream.EventChirpCreated, author, nested, &reply), want: true},
+		{name: "Reply to new reply", event: chirpEvent(stream.EventChirpCreated, author, uuid.New(), &nested), want: true},
+		{name: "Unrelated chirp", event: chirpEvent(stream.EventChirpCreated, author, uuid.New(), nil), want: false},
+		{name: "Reply elsewhere", event: chirpEvent(stream.EventChirpCreated, author, uuid.New(), ptr(uuid.New())), want: false},
+		{name: "Deleted member", event: chirpEvent(stream.EventChirpDeleted, author, nested, &reply), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := topics.MatchChirpEvent(tt.event)
+			matched := len(got) == 1 && got[0].Topic == TopicThread && *got[0].ChirpID == root
+			if matched != tt.want {
+				t.Errorf("MatchChirpEvent() = %v, want thread match %v", got, tt.want)
+			}
+		})
+	}
+
+	topics.UnsubscribeThread(root)
+	if got := topics.MatchChirpEvent(chirpEvent(stream.EventChirpCreated, author, uuid.New(), &root)); len(got) != 0 {
+		t.Errorf("matched %v after unsubscribing", got)
+	}
+}
+
+func ptr(id uuid.UUID) *uuid.UUID {
+	return &id
+}
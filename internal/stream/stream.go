@@ -0,0 +1,200 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// Channel is the Postgres NOTIFY channel chirp events travel over, so
+	// that every instance sees the chirps created on every other one.
+	Channel = "chirp_events"
+	// NotificationChannel carries notification.created events, which are
+	// private to their recipient and never reach the public chirp stream.
+	NotificationChannel = "notification_events"
+
+	EventChirpCreated        = "chirp.created"
+	EventChirpDeleted        = "chirp.deleted"
+	EventNotificationCreated = "notification.created"
+
+	DefaultReplaySize = 1024
+	// SubscriberBuffer is how many events a subscriber may fall behind by
+	// before it is dropped as a slow consumer.
+	SubscriberBuffer = 64
+
+	listenerPingInterval = 90 * time.Second
+)
+
+// Event is one change to the chirp stream. IDs come from a Postgres sequence,
+// so they mean the same thing on every instance and clients can resume from
+// any of them. They are taken when an event is sent but NOTIFY delivers in
+// commit order, so IDs can arrive out of order; the order of arrival is the
+// same on every instance and is what resuming follows. UserID is set on
+// events addressed to a single user.
+type Event struct {
+	ID       int64           `json:"id"`
+	Type     string          `json:"type"`
+	AuthorID uuid.UUID       `json:"author_id"`
+	UserID   uuid.UUID       `json:"user_id"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Filter picks the events a subscriber wants. A nil Filter accepts all.
+type Filter func(Event) bool
+
+func (f Filter) match(event Event) bool {
+	return f == nil || f(event)
+}
+
+// Subscription delivers live events to one consumer until it is closed or
+// dropped for falling behind.
+type Subscription struct {
+	Events  <-chan Event
+	events  chan Event
+	dropped chan struct{}
+	filter  Filter
+	hub     *Hub
+}
+
+// Dropped is closed when the hub gives up on a subscriber whose buffer is
+// full. The consumer should disconnect; it can catch up from the replay
+// buffer when it comes back.
+func (s *Subscription) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	delete(s.hub.subscribers, s)
+}
+
+// Hub fans chirp events out to local subscribers and keeps the most recent
+// ones for clients resuming after a disconnect.
+type Hub struct {
+	mu          sync.Mutex
+	replay      []Event
+	replaySize  int
+	subscribers map[*Subscription]struct{}
+}
+
+func NewHub(replaySize int) *Hub {
+	return &Hub{replaySize: replaySize, subscribers: map[*Subscription]struct{}{}}
+}
+
+// Publish records event in the replay buffer and hands it to every matching
+// subscriber. It never blocks; subscribers without room are dropped.
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.replay = append(h.replay, event)
+	if len(h.replay) > h.replaySize {
+		h.replay = h.replay[len(h.replay)-h.replaySize:]
+	}
+
+	for sub := range h.subscribers {
+		if !sub.filter.match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.dropped)
+		}
+	}
+}
+
+// Shutdown drops every subscriber so long-lived consumers such as open
+// streams return and let the server stop.
+func (h *Hub) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.dropped)
+	}
+}
+
+// Subscribe registers a consumer for events matching filter. It also returns
+// the buffered events that arrived after the one with lastID, and whether
+// they are complete: false means lastID is no longer in the buffer, so what
+// came after it is unknown and the consumer should refetch instead of
+// relying on the stream. A lastID of 0 asks for live events only.
+func (h *Hub) Subscribe(lastID int64, filter Filter) (*Subscription, []Event, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make(chan Event, SubscriberBuffer)
+	sub := &Subscription{Events: events, events: events, dropped: make(chan struct{}), filter: filter, hub: h}
+	h.subscribers[sub] = struct{}{}
+
+	if lastID == 0 {
+		return sub, nil, true
+	}
+	// Replay by position rather than by ID: an event with a lower ID can
+	// arrive after lastID did, and the client has not seen it.
+	start := -1
+	for i, event := range h.replay {
+		if event.ID == lastID {
+			start = i + 1
+			break
+		}
+	}
+	backlog := []Event{}
+	if start < 0 {
+		// Best effort for a consumer that is about to refetch anyway.
+		for _, event := range h.replay {
+			if event.ID > lastID && filter.match(event) {
+				backlog = append(backlog, event)
+			}
+		}
+		return sub, backlog, false
+	}
+	for _, event := range h.replay[start:] {
+		if filter.match(event) {
+			backlog = append(backlog, event)
+		}
+	}
+	return sub, backlog, true
+}
+
+// Listen publishes the events arriving on listener until ctx is cancelled.
+func (h *Hub) Listen(ctx context.Context, listener *pq.Listener) {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-listener.Notify:
+			if notification == nil {
+				// The connection was re-established and anything sent in
+				// between is lost. Forget the buffered events so resuming
+				// clients are told to refetch rather than silently
+				// skipping the gap.
+				log.Printf("stream listener reconnected")
+				h.mu.Lock()
+				h.replay = nil
+				h.mu.Unlock()
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				log.Printf("decoding stream event: %v", err)
+				continue
+			}
+			h.Publish(event)
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func publishN(hub *Hub, author uuid.UUID, from, to int64) {
+	for id := from; id <= to; id++ {
+		hub.Publish(Event{ID: id, Type: EventChirpCreated, AuthorID: author})
+	}
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	author := uuid.New()
+	hub := NewHub(5)
+	publishN(hub, author, 1, 8)
+
+	tests := []struct {
+		name         string
+		lastID       int64
+		wantIDs      []int64
+		wantComplete bool
+	}{
+		{name: "Live only", lastID: 0, wantIDs: nil, wantComplete: true},
+		{name: "Within buffer", lastID: 6, wantIDs: []int64{7, 8}, wantComplete: true},
+		{name: "Just before the buffer", lastID: 3, wantIDs: []int64{4, 5, 6, 7, 8}, wantComplete: false},
+		{name: "Fell out of buffer", lastID: 1, wantIDs: []int64{4, 5, 6, 7, 8}, wantComplete: false},
+		{name: "Up to date", lastID: 8, wantIDs: []int64{}, wantComplete: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, backlog, complete := hub.Subscribe(tt.lastID, nil)
+			defer sub.Close()
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+			if len(backlog) != len(tt.wantIDs) {
+				t.Fatalf("backlog has %d events, want %d", len(backlog), len(tt.wantIDs))
+			}
+			for i, event := range backlog {
+				if event.ID != tt.wantIDs[i] {
+					t.Errorf("backlog[%d].ID = %d, want %d", i, event.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSubscribeReplayOutOfOrder(t *testing.T) {
+	author := uuid.New()
+	hub := NewHub(DefaultReplaySize)
+	// 6 committed before 5, so it was delivered first.
+	for _, id := range []int64{4, 6, 5, 7} {
+		hub.Publish(Event{ID: id, Type: EventChirpCreated, AuthorID: author})
+	}
+
+	sub, backlog, complete := hub.Subscribe(6, nil)
+	defer sub.Close()
+	if !complete {
+		t.Errorf("complete = false, want true")
+	}
+	got := []int64{}
+	for _, event := range backlog {
+		got = append(got, event.ID)
+	}
+	if len(got) != 2 || got[0] != 5 || got[1] != 7 {
+		t.Errorf("backlog IDs = %v, want [5 7]", got)
+	}
+}
+
+func TestSubscribeFilter(t *testing.T) {
+	author, other := uuid.New(), uuid.New()
+	hub := NewHub(DefaultReplaySize)
+	sub, _, _ := hub.Subscribe(0, func(e Event) bool { return e.AuthorID == author })
+	defer sub.Close()
+
+	hub.Publish(Event{ID: 1, AuthorID: other})
+	hub.Publish(Event{ID: 2, AuthorID: author})
+
+	select {
+	case event := <-sub.Events:
+		if event.ID != 2 {
+			t.Errorf("received event %d, want 2", event.ID)
+		}
+	default:
+		t.Fatalf("no event delivered")
+	}
+	select {
+	case event := <-sub.Events:
+		t.Errorf("unexpected event %d", event.ID)
+	default:
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	hub := NewHub(DefaultReplaySize)
+	slow, _, _ := hub.Subscribe(0, nil)
+	fast, _, _ := hub.Subscribe(0, nil)
+	defer fast.Close()
+
+	for id := int64(1); id <= SubscriberBuffer+1; id++ {
+		hub.Publish(Event{ID: id})
+		<-fast.Events
+	}
+
+	select {
+	case <-slow.Dropped():
+	default:
+		t.Fatalf("slow subscriber was not dropped")
+	}
+	select {
+	case <-fast.Dropped():
+		t.Errorf("fast subscriber was dropped")
+	default:
+	}
+	slow.Close()
+}
+
+func TestShutdownDropsSubscribers(t *testing.T) {
+	hub := NewHub(DefaultReplaySize)
+	sub, _, _ := hub.Subscribe(0, nil)
+
+	hub.Shutdown()
+	select {
+	case <-sub.Dropped():
+	default:
+		t.Fatalf("subscriber not dropped on shutdown")
+	}
+	sub.Close()
+}
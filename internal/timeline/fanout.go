@@ -0,0 +1,186 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultThreshold is the follower count above which an author's chirps
+	// are no longer copied into each follower's timeline.
+	DefaultThreshold = 10000
+	DefaultQueueSize = 1024
+)
+
+// Store is the part of the database the fan-out works with.
+// *database.Queries implements it.
+type Store interface {
+	GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	CountFollowers(ctx context.Context, followeeID uuid.UUID) (int64, error)
+	LockAuthorTimelines(ctx context.Context, authorID uuid.UUID) error
+	FanOutChirp(ctx context.Context, id uuid.UUID) (int64, error)
+	MarkChirpFannedOut(ctx context.Context, id uuid.UUID) error
+	BackfillTimelineFromAuthor(ctx context.Context, arg database.BackfillTimelineFromAuthorParams) error
+	DeleteTimelineEntriesFromAuthor(ctx context.Context, arg database.DeleteTimelineEntriesFromAuthorParams) error
+	DeleteTimelineEntriesForUser(ctx context.Context, userID uuid.UUID) error
+	RebuildTimelineForUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	CountMissingTimelineEntries(ctx context.Context, userID uuid.UUID) (int64, error)
+	CountStaleTimelineEntries(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+// Fanout maintains the materialized home timelines in timeline_entries.
+// New chirps are copied into their followers' timelines by a background
+// worker. Chirps it has not copied, because the author has too many
+// followers or the worker has not reached them yet, are merged in at read
+// time by GetCachedTimeline.
+type Fanout struct {
+	Db        Store
+	Threshold int64
+	// InTx runs fn in a transaction, with a Store bound to it, and commits
+	// if fn succeeds.
+	InTx  func(ctx context.Context, fn func(Store) error) error
+	queue chan uuid.UUID
+}
+
+func NewFanout(db *database.Queries, conn *sql.DB, threshold int64, queueSize int) *Fanout {
+	inTx := func(ctx context.Context, fn func(Store) error) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := fn(db.WithTx(tx)); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	return &Fanout{Db: db, Threshold: threshold, InTx: inTx, queue: make(chan uuid.UUID, queueSize)}
+}
+
+// Enqueue schedules a newly created chirp for fan-out. It never blocks; when
+// the queue is full the chirp is dropped and stays visible through the
+// read-time fallback until the affected timelines are rebuilt.
+func (f *Fanout) Enqueue(chirpID uuid.UUID) bool {
+	select {
+	case f.queue <- chirpID:
+		return true
+	default:
+		log.Printf("timeline fan-out queue full, dropping chirp %s", chirpID)
+		return false
+	}
+}
+
+// Run processes queued chirps until ctx is cancelled.
+func (f *Fanout) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chirpID := <-f.queue:
+			if err := f.FanOut(ctx, chirpID); err != nil {
+				log.Printf("fanning out chirp %s: %v", chirpID, err)
+			}
+		}
+	}
+}
+
+// FanOut copies a chirp into the timelines of its author and their
+// followers, unless the author is above the follower threshold.
+func (f *Fanout) FanOut(ctx context.Context, chirpID uuid.UUID) error {
+	chirp, err := f.Db.GetChirp(ctx, chirpID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	followers, err := f.Db.CountFollowers(ctx, chirp.UserID)
+	if err != nil {
+		return err
+	}
+	if followers > f.Threshold {
+		return nil
+	}
+
+	// The author's lock is held from before the followers are read until
+	// the chirp is marked fanned out, so a Follow or Unfollow of the author
+	// either finishes first, and is reflected here, or waits and then sees
+	// the chirp as fanned out.
+	return f.InTx(ctx, func(tx Store) error {
+		if err := tx.LockAuthorTimelines(ctx, chirp.UserID); err != nil {
+			return err
+		}
+		if _, err := tx.FanOutChirp(ctx, chirp.ID); err != nil {
+			return err
+		}
+		return tx.MarkChirpFannedOut(ctx, chirp.ID)
+	})
+}
+
+// Follow copies the author's already fanned-out chirps into a new
+// follower's timeline. The follow must already be committed.
+func (f *Fanout) Follow(ctx context.Context, userID, authorID uuid.UUID) error {
+	return f.InTx(ctx, func(tx Store) error {
+		if err := tx.LockAuthorTimelines(ctx, authorID); err != nil {
+			return err
+		}
+		return tx.BackfillTimelineFromAuthor(ctx, database.BackfillTimelineFromAuthorParams{UserID: userID, AuthorID: authorID})
+	})
+}
+
+// Unfollow removes the author's chirps from a former follower's timeline.
+// The unfollow must already be committed.
+func (f *Fanout) Unfollow(ctx context.Context, userID, authorID uuid.UUID) error {
+	return f.InTx(ctx, func(tx Store) error {
+		if err := tx.LockAuthorTimelines(ctx, authorID); err != nil {
+			return err
+		}
+		return tx.DeleteTimelineEntriesFromAuthor(ctx, database.DeleteTimelineEntriesFromAuthorParams{UserID: userID, AuthorID: authorID})
+	})
+}
+
+// Rebuild discards a user's materialized timeline and recomputes it from
+// the follow graph, returning the number of entries written.
+func (f *Fanout) Rebuild(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var written int64
+	err := f.InTx(ctx, func(tx Store) error {
+		if err := tx.DeleteTimelineEntriesForUser(ctx, userID); err != nil {
+			return err
+		}
+		var err error
+		written, err = tx.RebuildTimelineForUser(ctx, userID)
+		return err
+	})
+	return written, err
+}
+
+// Report describes how far a user's materialized timeline has drifted from
+// what the follow graph says it should hold.
+type Report struct {
+	// Missing counts fanned-out chirps the user should see but has no
+	// entry for.
+	Missing int64 `json:"missing"`
+	// Stale counts entries from authors the user no longer follows.
+	Stale int64 `json:"stale"`
+}
+
+func (r Report) Consistent() bool {
+	return r.Missing == 0 && r.Stale == 0
+}
+
+func (f *Fanout) Check(ctx context.Context, userID uuid.UUID) (Report, error) {
+	missing, err := f.Db.CountMissingTimelineEntries(ctx, userID)
+	if err != nil {
+		return Report{}, err
+	}
+	stale, err := f.Db.CountStaleTimelineEntries(ctx, userID)
+	if err != nil {
+		return Report{}, err
+	}
+	return Report{Missing: missing, Stale: stale}, nil
+}
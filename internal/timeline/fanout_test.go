@@ -0,0 +1,355 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestEnqueueDropsWhenFull(t *testing.T) {
+	fanout := NewFanout(nil, nil, DefaultThreshold, 1)
+
+	if !fanout.Enqueue(uuid.New()) {
+		t.Fatalf("Enqueue() on an empty queue = false, want true")
+	}
+	if fanout.Enqueue(uuid.New()) {
+		t.Errorf("Enqueue() on a full queue = true, want false")
+	}
+}
+
+func TestReportConsistent(t *testing.T) {
+	tests := []struct {
+		name   string
+		report Report
+		want   bool
+	}{
+		{name: "Clean", report: Report{}, want: true},
+		{name: "Missing entries", report: Report{Missing: 3}, want: false},
+		{name: "Stale entries", report: Report{Stale: 1}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.Consistent(); got != tt.want {
+				t.Errorf("Consistent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type timelineEntry struct {
+	userID  uuid.UUID
+	chirpID uuid.UUID
+}
+
+// fakeStore keeps chirps, follows and timeline entries in memory. Writes are
+// visible at once; the only transactional behaviour it has is the author
+// lock, which is held until the end of the InTx call that took it.
+type fakeStore struct {
+	mu        sync.Mutex
+	chirps    map[uuid.UUID]*database.Chirp
+	followers map[uuid.UUID][]uuid.UUID
+	entries   map[timelineEntry]bool
+	locks     map[uuid.UUID]*sync.Mutex
+	// fanOutHook, when set, runs in FanOutChirp after the followers are read.
+	fanOutHook func()
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		chirps:    map[uuid.UUID]*database.Chirp{},
+		followers: map[uuid.UUID][]uuid.UUID{},
+		entries:   map[timelineEntry]bool{},
+		locks:     map[uuid.UUID]*sync.Mutex{},
+	}
+}
+
+func (s *fakeStore) addChirp(authorID uuid.UUID, fannedOut, deleted bool) uuid.UUID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirp := &database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UserID: authorID}
+	if fannedOut {
+		chirp.FannedOutAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	if deleted {
+		chirp.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	s.chirps[chirp.ID] = chirp
+	return chirp.ID
+}
+
+func (s *fakeStore) follow(followerID, authorID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.followers[authorID] = append(s.followers[authorID], followerID)
+}
+
+func (s *fakeStore) hasEntry(userID, chirpID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[timelineEntry{userID, chirpID}]
+}
+
+func (s *fakeStore) fannedOut(chirpID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chirps[chirpID].FannedOutAt.Valid
+}
+
+func (s *fakeStore) inTx(ctx context.Context, fn func(Store) error) error {
+	tx := &fakeTx{fakeStore: s}
+	defer func() {
+		for _, lock := range tx.held {
+			lock.Unlock()
+		}
+	}()
+	return fn(tx)
+}
+
+func (s *fakeStore) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirp, ok := s.chirps[id]
+	if !ok || chirp.DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return *chirp, nil
+}
+
+func (s *fakeStore) CountFollowers(ctx context.Context, followeeID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.followers[followeeID])), nil
+}
+
+// LockAuthorTimelines outside a transaction is released straight away, like
+// pg_advisory_xact_lock in autocommit mode.
+func (s *fakeStore) LockAuthorTimelines(ctx context.Context, authorID uuid.UUID) error {
+	return nil
+}
+
+func (s *fakeStore) FanOutChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	chirp := s.chirps[id]
+	users := append([]uuid.UUID{chirp.UserID}, s.followers[chirp.UserID]...)
+	s.mu.Unlock()
+
+	if s.fanOutHook != nil {
+		s.fanOutHook()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, userID := range users {
+		s.entries[timelineEntry{userID, id}] = true
+	}
+	return int64(len(users)), nil
+}
+
+func (s *fakeStore) MarkChirpFannedOut(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chirps[id].FannedOutAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return nil
+}
+
+func (s *fakeStore) BackfillTimelineFromAuthor(ctx context.Context, arg database.BackfillTimelineFromAuthorParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, chirp := range s.chirps {
+		if chirp.UserID == arg.AuthorID && chirp.FannedOutAt.Valid && !chirp.DeletedAt.Valid {
+			s.entries[timelineEntry{arg.UserID, chirp.ID}] = true
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) DeleteTimelineEntriesFromAuthor(ctx context.Context, arg database.DeleteTimelineEntriesFromAuthorParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for entry := range s.entries {
+		if entry.userID == arg.UserID && s.chirps[entry.chirpID].UserID == arg.AuthorID {
+			delete(s.entries, entry)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) DeleteTimelineEntriesForUser(ctx context.Context, userID uuid.UUID) error {
+	return nil
+}
+
+func (s *fakeStore) RebuildTimelineForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) CountMissingTimelineEntries(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) CountStaleTimelineEntries(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return 0, nil
+}
+
+// fakeTx is a fakeStore seen from inside InTx.
+type fakeTx struct {
+	*fakeStore
+	held []*sync.Mutex
+}
+
+func (tx *fakeTx) LockAuthorTimelines(ctx context.Context, authorID uuid.UUID) error {
+	tx.mu.Lock()
+	lock, ok := tx.locks[authorID]
+	if !ok {
+		lock = &sync.Mutex{}
+		tx.locks[authorID] = lock
+	}
+	tx.mu.Unlock()
+
+	lock.Lock()
+	tx.held = append(tx.held, lock)
+	return nil
+}
+
+func newTestFanout(store *fakeStore, threshold int64) *Fanout {
+	return &Fanout{Db: store, Threshold: threshold, InTx: store.inTx}
+}
+
+func TestFanOut(t *testing.T) {
+	tests := []struct {
+		name       string
+		followers  int
+		threshold  int64
+		wantMarked bool
+	}{
+		{name: "No followers", followers: 0, threshold: 2, wantMarked: true},
+		{name: "At threshold", followers: 2, threshold: 2, wantMarked: true},
+		{name: "Above threshold", followers: 3, threshold: 2, wantMarked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			author := uuid.New()
+			followers := []uuid.UUID{}
+			for range tt.followers {
+				follower := uuid.New()
+				store.follow(follower, author)
+				followers = append(followers, follower)
+			}
+			chirp := store.addChirp(author, false, false)
+
+			if err := newTestFanout(store, tt.threshold).FanOut(context.Background(), chirp); err != nil {
+				t.Fatalf("FanOut() error = %v", err)
+			}
+			if got := store.fannedOut(chirp); got != tt.wantMarked {
+				t.Errorf("fanned out = %v, want %v", got, tt.wantMarked)
+			}
+			// Above the threshold the chirp is left to the read-time
+			// fallback, so nobody gets an entry.
+			for _, userID := range append(followers, author) {
+				if got := store.hasEntry(userID, chirp); got != tt.wantMarked {
+					t.Errorf("entry for %s = %v, want %v", userID, got, tt.wantMarked)
+				}
+			}
+		})
+	}
+}
+
+func TestFanOutDeletedChirp(t *testing.T) {
+	store := newFakeStore()
+	author := uuid.New()
+	chirp := store.addChirp(author, false, true)
+
+	if err := newTestFanout(store, DefaultThreshold).FanOut(context.Background(), chirp); err != nil {
+		t.Fatalf("FanOut() error = %v", err)
+	}
+	if store.fannedOut(chirp) || store.hasEntry(author, chirp) {
+		t.Errorf("deleted chirp was fanned out")
+	}
+}
+
+func TestFollowBackfill(t *testing.T) {
+	store := newFakeStore()
+	author, other, follower := uuid.New(), uuid.New(), uuid.New()
+	fannedOut := store.addChirp(author, true, false)
+	pending := store.addChirp(author, false, false)
+	deleted := store.addChirp(author, true, true)
+	otherChirp := store.addChirp(other, true, false)
+	fanout := newTestFanout(store, DefaultThreshold)
+
+	store.follow(follower, author)
+	if err := fanout.Follow(context.Background(), follower, author); err != nil {
+		t.Fatalf("Follow() error = %v", err)
+	}
+	tests := []struct {
+		name  string
+		chirp uuid.UUID
+		want  bool
+	}{
+		{name: "Fanned out", chirp: fannedOut, want: true},
+		{name: "Not yet fanned out", chirp: pending, want: false},
+		{name: "Deleted", chirp: deleted, want: false},
+		{name: "Another author", chirp: otherChirp, want: false},
+	}
+	for _, tt := range tests {
+		if got := store.hasEntry(follower, tt.chirp); got != tt.want {
+			t.Errorf("%s: entry = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	store.follow(follower, other)
+	if err := fanout.Follow(context.Background(), follower, other); err != nil {
+		t.Fatalf("Follow() error = %v", err)
+	}
+	if err := fanout.Unfollow(context.Background(), follower, author); err != nil {
+		t.Fatalf("Unfollow() error = %v", err)
+	}
+	if store.hasEntry(follower, fannedOut) || !store.hasEntry(follower, otherChirp) {
+		t.Errorf("Unfollow() should remove only the author's entries")
+	}
+}
+
+func TestFollowDuringFanOut(t *testing.T) {
+	store := newFakeStore()
+	author, follower := uuid.New(), uuid.New()
+	chirp := store.addChirp(author, false, false)
+	fanout := newTestFanout(store, DefaultThreshold)
+
+	readFollowers := make(chan struct{})
+	release := make(chan struct{})
+	store.fanOutHook = func() {
+		close(readFollowers)
+		<-release
+	}
+	fanOutErr := make(chan error)
+	go func() { fanOutErr <- fanout.FanOut(context.Background(), chirp) }()
+	<-readFollowers
+
+	// The follow lands after the fan-out has read the followers but before
+	// the chirp is marked fanned out.
+	store.follow(follower, author)
+	followErr := make(chan error)
+	go func() { followErr <- fanout.Follow(context.Background(), follower, author) }()
+	select {
+	case err := <-followErr:
+		t.Fatalf("Follow() = %v while the author's chirp was being fanned out, want it to wait", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-fanOutErr; err != nil {
+		t.Fatalf("FanOut() error = %v", err)
+	}
+	if err := <-followErr; err != nil {
+		t.Fatalf("Follow() error = %v", err)
+	}
+	if !store.hasEntry(follower, chirp) {
+		t.Errorf("new follower is missing the chirp fanned out during the follow")
+	}
+}
@@ -0,0 +1,177 @@
+package trends
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+)
+
+const (
+	DefaultWindows  = "1h,24h"
+	DefaultInterval = 5 * time.Minute
+
+	// BaselineWindows is how many windows before the current one make up a
+	// tag's normal volume.
+	BaselineWindows = 7
+	// MinUses keeps a handful of chirps from making a tag trend.
+	MinUses = 3
+	// MaxTrends is how many tags each snapshot keeps.
+	MaxTrends = 20
+	// SnapshotRetention is how long old computations and their snapshots
+	// are kept around.
+	SnapshotRetention = 7 * 24 * time.Hour
+)
+
+var ErrInvalidWindow = errors.New("invalid trend window")
+
+// Window is a sliding period trends are computed over, named the way it is
+// configured and requested, e.g. "1h".
+type Window struct {
+	Name     string
+	Duration time.Duration
+}
+
+// ParseWindows reads a comma-separated list of durations such as "1h,24h".
+func ParseWindows(s string) ([]Window, error) {
+	windows := []Window{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		duration, err := time.ParseDuration(name)
+		if err != nil || duration <= 0 {
+			return nil, ErrInvalidWindow
+		}
+		windows = append(windows, Window{Name: name, Duration: duration})
+	}
+	return windows, nil
+}
+
+// Trend is a ranked tag in a snapshot.
+type Trend struct {
+	Tag   string
+	Score float64
+	Uses  int64
+}
+
+// Score rates how unusual a tag's recent activity is. decayed is the tag's
+// use count in the window with older uses discounted; baselineUses is its
+// count over the BaselineWindows windows before that. A tag that is always
+// busy scores lower than one that suddenly took off.
+func Score(decayed float64, baselineUses int64) float64 {
+	expected := float64(baselineUses) / BaselineWindows
+	return decayed / (expected + 1)
+}
+
+// Rank scores stats and returns the top limit tags, leaving out any tag in
+// exclude.
+func Rank(stats []database.GetHashtagWindowStatsRow, exclude []string, limit int) []Trend {
+	ranked := []Trend{}
+	for _, stat := range stats {
+		if slices.Contains(exclude, stat.Tag) {
+			continue
+		}
+		ranked = append(ranked, Trend{Tag: stat.Tag, Score: Score(stat.Decayed, stat.BaselineUses), Uses: stat.Uses})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Tag < ranked[j].Tag
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// Aggregator periodically recomputes trending hashtags for each window and
+// stores the results as snapshots.
+type Aggregator struct {
+	Db      *database.Queries
+	DBConn  *sql.DB
+	Windows []Window
+	// Exclude lists tags that must never trend, lowercased.
+	Exclude []string
+	now     func() time.Time
+}
+
+func NewAggregator(db *database.Queries, conn *sql.DB, windows []Window, exclude []string) *Aggregator {
+	return &Aggregator{Db: db, DBConn: conn, Windows: windows, Exclude: exclude, now: time.Now}
+}
+
+// Run computes trends immediately and then every interval until ctx is
+// cancelled.
+func (a *Aggregator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := a.Compute(ctx); err != nil {
+			log.Printf("computing trends: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compute stores a fresh snapshot for every window and prunes old ones. A
+// window with nothing trending still gets an empty snapshot, so readers
+// never fall back to an older one.
+func (a *Aggregator) Compute(ctx context.Context) error {
+	now := a.now().UTC()
+	for _, window := range a.Windows {
+		if err := a.computeWindow(ctx, window, now); err != nil {
+			return err
+		}
+	}
+	return a.Db.DeleteTrendComputationsBefore(ctx, now.Add(-SnapshotRetention))
+}
+
+func (a *Aggregator) computeWindow(ctx context.Context, window Window, now time.Time) error {
+	windowStart := now.Add(-window.Duration)
+	stats, err := a.Db.GetHashtagWindowStats(ctx, database.GetHashtagWindowStatsParams{
+		WindowStart:     windowStart,
+		Now:             now,
+		HalfLifeSeconds: math.Max(window.Duration.Seconds()/2, 1),
+		BaselineStart:   windowStart.Add(-BaselineWindows * window.Duration),
+		MinUses:         MinUses,
+	})
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := a.Db.WithTx(tx)
+	err = qtx.CreateTrendComputation(ctx, database.CreateTrendComputationParams{WindowName: window.Name, ComputedAt: now})
+	if err != nil {
+		return err
+	}
+	for i, trend := range Rank(stats, a.Exclude, MaxTrends) {
+		err := qtx.CreateTrendSnapshot(ctx, database.CreateTrendSnapshotParams{
+			WindowName: window.Name,
+			ComputedAt: now,
+			Rank:       int32(i + 1),
+			Tag:        trend.Tag,
+			Score:      trend.Score,
+			Uses:       trend.Uses,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
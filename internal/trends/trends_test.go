@@ -0,0 +1,64 @@
+package trends
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+)
+
+func TestParseWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Window
+		wantErr error
+	}{
+		{name: "Defaults", input: DefaultWindows, want: []Window{{"1h", time.Hour}, {"24h", 24 * time.Hour}}},
+		{name: "Spaces", input: " 30m , 6h", want: []Window{{"30m", 30 * time.Minute}, {"6h", 6 * time.Hour}}},
+		{name: "Not a duration", input: "1h,day", wantErr: ErrInvalidWindow},
+		{name: "Zero", input: "0s", wantErr: ErrInvalidWindow},
+		{name: "Empty entry", input: "1h,", wantErr: ErrInvalidWindow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWindows(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseWindows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWindows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreFavorsSpikes(t *testing.T) {
+	steady := Score(10, 70)
+	spike := Score(10, 0)
+	if spike <= steady {
+		t.Errorf("Score() for a new tag = %v, want more than a steady tag's %v", spike, steady)
+	}
+}
+
+func TestRank(t *testing.T) {
+	stats := []database.GetHashtagWindowStatsRow{
+		{Tag: "steady", Uses: 10, Decayed: 8, BaselineUses: 70},
+		{Tag: "fornax", Uses: 50, Decayed: 45, BaselineUses: 0},
+		{Tag: "breaking", Uses: 10, Decayed: 8, BaselineUses: 0},
+		{Tag: "alpha", Uses: 5, Decayed: 4, BaselineUses: 0},
+		{Tag: "beta", Uses: 5, Decayed: 4, BaselineUses: 0},
+	}
+
+	got := Rank(stats, []string{"fornax"}, 3)
+	tags := []string{}
+	for _, trend := range got {
+		tags = append(tags, trend.Tag)
+	}
+	if want := []string{"breaking", "alpha", "beta"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Rank() tags = %v, want %v", tags, want)
+	}
+}
@@ -0,0 +1,94 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for a user webhook whose host is the server
+// itself or on a private network.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// nonPublicRanges are ranges the net.IP predicates do not cover: "this
+// network", which Linux routes to the local host, and carrier-grade NAT
+// shared address space.
+var nonPublicRanges = []*net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+}
+
+// PublicAddress reports whether user webhooks may be sent to ip: it must not
+// be loopback, private, link-local, unspecified, in 0.0.0.0/8 or in the
+// carrier-grade NAT range 100.64.0.0/10.
+func PublicAddress(ip net.IP) bool {
+	if ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsUnspecified() {
+		return false
+	}
+	for _, ipNet := range nonPublicRanges {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckHost resolves host and returns ErrPrivateAddress if any of its
+// addresses is not public. It lets a user webhook be refused when it is
+// created; the dispatcher checks again whenever it connects.
+func CheckHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !PublicAddress(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// publicOnly is a net.Dialer Control function refusing non-public addresses.
+// It runs after name resolution, for every address tried, so a host that
+// resolves differently at delivery time than at creation is still caught.
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !PublicAddress(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// newClient returns a client for sending deliveries. Redirects are never
+// followed, so a receiver cannot bounce a delivery somewhere else. A public
+// client refuses to connect to non-public addresses and ignores proxies from
+// the environment, which would otherwise connect on its behalf.
+func newClient(timeout time.Duration, public bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if public {
+		dialer.Control = publicOnly
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,264 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserCreated  = "user.created"
+
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+
+	SignatureHeader = "X-Chirpy-Signature"
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+
+	DefaultPollInterval = 5 * time.Second
+	DefaultMaxAttempts  = 8
+	DefaultBaseBackoff  = 30 * time.Second
+	MaxBackoff          = 6 * time.Hour
+	DefaultBatchSize    = 50
+	DefaultTimeout      = 10 * time.Second
+
+	// SignatureTolerance is how old a signature Verify accepts, to limit
+	// replays of captured requests.
+	SignatureTolerance = 5 * time.Minute
+)
+
+var (
+	// Events lists every event a webhook can subscribe to.
+	Events = []string{EventChirpCreated, EventChirpDeleted, EventUserCreated}
+
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Envelope is the body of every delivery. ID identifies the event, so a
+// receiver can tell a retried delivery from a new event.
+type Envelope struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func ValidEvent(event string) bool {
+	return slices.Contains(Events, event)
+}
+
+func NewSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Sign returns the signature header for body sent at timestamp: the Unix time
+// and an HMAC-SHA256 over "<time>.<body>" keyed with the webhook's secret.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix + "."))
+	mac.Write(body)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header the way a receiver should: the HMAC must
+// match and the timestamp must be within SignatureTolerance of now.
+func Verify(secret, header string, body []byte, now time.Time) error {
+	var unix, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			signature = value
+		}
+	}
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidSignature
+	}
+	timestamp := time.Unix(seconds, 0)
+	if now.Sub(timestamp).Abs() > SignatureTolerance {
+		return ErrInvalidSignature
+	}
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte("t="+unix+",v1="+signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Backoff is the wait before retrying after the given number of failed
+// attempts: base, doubled for each attempt after the first, capped at
+// MaxBackoff.
+func Backoff(base time.Duration, attempts int) time.Duration {
+	wait := base
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return wait
+}
+
+// Queue is the delivery queue a Dispatcher works from. *database.Queries
+// implements it.
+type Queue interface {
+	ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.ClaimWebhookDeliveriesRow, error)
+	RecordWebhookAttempt(ctx context.Context, arg database.RecordWebhookAttemptParams) error
+}
+
+// Dispatcher delivers queued webhook deliveries. Deliveries are claimed with
+// SKIP LOCKED, so any number of instances can run one. A delivery that
+// fails MaxAttempts times is marked dead and left for its owner to inspect
+// and retry. Users' webhooks are sent with Client, which only reaches public
+// addresses; admin webhooks are sent with AdminClient, which may also reach
+// internal hosts.
+type Dispatcher struct {
+	Db          Queue
+	Client      *http.Client
+	AdminClient *http.Client
+	MaxAttempts int
+	BaseBackoff time.Duration
+	BatchSize   int
+}
+
+func NewDispatcher(db Queue) *Dispatcher {
+	return &Dispatcher{
+		Db:          db,
+		Client:      newClient(DefaultTimeout, true),
+		AdminClient: newClient(DefaultTimeout, false),
+		MaxAttempts: DefaultMaxAttempts,
+		BaseBackoff: DefaultBaseBackoff,
+		BatchSize:   DefaultBatchSize,
+	}
+}
+
+// Run delivers due webhooks every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.DeliverDue(ctx); err != nil {
+				log.Printf("delivering webhooks: %v", err)
+			}
+		}
+	}
+}
+
+// DeliverDue sends up to BatchSize due deliveries and records the outcomes.
+// Deliveries are claimed one at a time, each hidden from other instances
+// for long enough to be attempted, so a slow receiver only holds up its own
+// delivery while other instances carry on with the rest. If this instance
+// dies mid-attempt, the delivery becomes due again when its lease runs out.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	lease := 2 * max(d.Client.Timeout, d.AdminClient.Timeout)
+	for range d.BatchSize {
+		due, err := d.Db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			MaxResults:   1,
+			LeaseSeconds: lease.Seconds(),
+		})
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		if err := d.deliver(ctx, due[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver makes one attempt at a claimed delivery and records the outcome.
+func (d *Dispatcher) deliver(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) error {
+	status, sendErr := d.Send(ctx, delivery.AllUsers, delivery.Url, delivery.Secret, delivery.ID, delivery.Event, delivery.Payload)
+	attempt := database.RecordWebhookAttemptParams{
+		ID:            delivery.ID,
+		Status:        StatusDelivered,
+		Attempts:      delivery.Attempts + 1,
+		NextAttemptAt: time.Now(),
+	}
+	if status != 0 {
+		attempt.ResponseStatus = sql.NullInt32{Int32: int32(status), Valid: true}
+	}
+	if sendErr != nil {
+		attempt.LastError = sql.NullString{String: sendErr.Error(), Valid: true}
+		attempt.Status = StatusPending
+		attempt.NextAttemptAt = time.Now().Add(Backoff(d.BaseBackoff, int(attempt.Attempts)))
+		if int(attempt.Attempts) >= d.MaxAttempts {
+			attempt.Status = StatusDead
+		}
+	}
+	return d.Db.RecordWebhookAttempt(ctx, attempt)
+}
+
+// Send makes one signed delivery attempt, with AdminClient for admin
+// webhooks and Client otherwise. Any response other than 2xx is an error,
+// redirects included; the status code is returned whenever there was a
+// response.
+func (d *Dispatcher) Send(ctx context.Context, allUsers bool, url, secret string, deliveryID uuid.UUID, event string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID.String())
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), payload))
+
+	client := d.Client
+	if allUsers {
+		client = d.AdminClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver responded %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// Payload builds the envelope for a new event.
+func Payload(event string, data any) (json.RawMessage, error) {
+	return json.Marshal(Envelope{ID: uuid.New(), Type: event, CreatedAt: time.Now().UTC(), Data: data})
+}
@@ -0,0 +1,300 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"type":"chirp.created"}`)
+	now := time.Now()
+	header := Sign(secret, now, body)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{name: "Valid", secret: secret, header: header, body: body, now: now},
+		{name: "Wrong secret", secret: "whsec_other", header: header, body: body, now: now, wantErr: ErrInvalidSignature},
+		{name: "Tampered body", secret: secret, header: header, body: []byte(`{"type":"user.created"}`), now: now, wantErr: ErrInvalidSignature},
+		{name: "Too old", secret: secret, header: header, body: body, now: now.Add(SignatureTolerance + time.Minute), wantErr: ErrInvalidSignature},
+		{name: "Malformed header", secret: secret, header: "v1=abc", body: body, now: now, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, tt.body, tt.now); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 4, want: 4 * time.Minute},
+		{attempts: 20, want: MaxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(DefaultBaseBackoff, tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	secret := "whsec_test"
+	deliveryID := uuid.New()
+	payload, err := Payload(EventChirpCreated, map[string]string{"body": "hello"})
+	if err != nil {
+		t.Fatalf("Payload() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus int
+		wantErr    bool
+	}{
+		{name: "Accepted", status: http.StatusNoContent, wantStatus: http.StatusNoContent},
+		{name: "Receiver error", status: http.StatusInternalServerError, wantStatus: http.StatusInternalServerError, wantErr: true},
+		{name: "Redirect is not success", status: http.StatusNotModified, wantStatus: http.StatusNotModified, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verifyErr error
+			var gotEvent, gotDelivery string
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				verifyErr = Verify(secret, r.Header.Get(SignatureHeader), body, time.Now())
+				gotEvent = r.Header.Get(EventHeader)
+				gotDelivery = r.Header.Get(DeliveryHeader)
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+
+			dispatcher := NewDispatcher(nil)
+			status, err := dispatcher.Send(context.Background(), true, receiver.URL, secret, deliveryID, EventChirpCreated, payload)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("Send() status = %d, want %d", status, tt.wantStatus)
+			}
+			if verifyErr != nil {
+				t.Errorf("receiver could not verify signature: %v", verifyErr)
+			}
+			if gotEvent != EventChirpCreated || gotDelivery != deliveryID.String() {
+				t.Errorf("headers = %q, %q; want %q, %q", gotEvent, gotDelivery, EventChirpCreated, deliveryID)
+			}
+		})
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	url := receiver.URL
+	receiver.Close()
+
+	status, err := NewDispatcher(nil).Send(context.Background(), true, url, "whsec_test", uuid.New(), EventUserCreated, []byte(`{}`))
+	if err == nil || status != 0 {
+		t.Errorf("Send() to a closed receiver = %d, %v; want 0 and an error", status, err)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{ip: "127.0.0.1", want: false},
+		{ip: "::1", want: false},
+		{ip: "10.1.2.3", want: false},
+		{ip: "172.16.0.1", want: false},
+		{ip: "192.168.1.1", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "169.254.169.254", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "0.0.0.0", want: false},
+		{ip: "::", want: false},
+		{ip: "::ffff:127.0.0.1", want: false},
+		{ip: "0.1.2.3", want: false},
+		{ip: "0.255.255.255", want: false},
+		{ip: "100.64.0.1", want: false},
+		{ip: "100.127.255.254", want: false},
+		{ip: "::ffff:100.64.0.1", want: false},
+		{ip: "100.63.255.255", want: true},
+		{ip: "100.128.0.1", want: true},
+		{ip: "1.0.0.1", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := PublicAddress(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("PublicAddress(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestSendPrivateAddress(t *testing.T) {
+	reached := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer receiver.Close()
+
+	status, err := NewDispatcher(nil).Send(context.Background(), false, receiver.URL, "whsec_test", uuid.New(), EventChirpCreated, []byte(`{}`))
+	if !errors.Is(err, ErrPrivateAddress) || status != 0 {
+		t.Errorf("Send() to loopback = %d, %v; want 0 and %v", status, err, ErrPrivateAddress)
+	}
+	if reached {
+		t.Error("user webhook reached a loopback receiver")
+	}
+}
+
+func TestSendRedirect(t *testing.T) {
+	reached := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer receiver.Close()
+
+	status, err := NewDispatcher(nil).Send(context.Background(), true, receiver.URL, "whsec_test", uuid.New(), EventChirpCreated, []byte(`{}`))
+	if err == nil || status != http.StatusTemporaryRedirect {
+		t.Errorf("Send() to a redirect = %d, %v; want %d and an error", status, err, http.StatusTemporaryRedirect)
+	}
+	if reached {
+		t.Error("Send() followed the redirect")
+	}
+}
+
+// fakeQueue is an in-memory delivery queue with the same lease semantics as
+// ClaimWebhookDeliveries: a claimed delivery is not due again until its lease
+// runs out or an attempt is recorded.
+type fakeQueue struct {
+	mu         sync.Mutex
+	deliveries []database.ClaimWebhookDeliveriesRow
+	dueAt      map[uuid.UUID]time.Time
+	status     map[uuid.UUID]string
+}
+
+func newFakeQueue(deliveries ...database.ClaimWebhookDeliveriesRow) *fakeQueue {
+	q := &fakeQueue{deliveries: deliveries, dueAt: map[uuid.UUID]time.Time{}, status: map[uuid.UUID]string{}}
+	for _, delivery := range deliveries {
+		q.status[delivery.ID] = StatusPending
+	}
+	return q
+}
+
+func (q *fakeQueue) ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.ClaimWebhookDeliveriesRow, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	claimed := []database.ClaimWebhookDeliveriesRow{}
+	for _, delivery := range q.deliveries {
+		if len(claimed) == int(arg.MaxResults) {
+			break
+		}
+		if q.status[delivery.ID] != StatusPending || q.dueAt[delivery.ID].After(now) {
+			continue
+		}
+		q.dueAt[delivery.ID] = now.Add(time.Duration(arg.LeaseSeconds * float64(time.Second)))
+		claimed = append(claimed, delivery)
+	}
+	return claimed, nil
+}
+
+func (q *fakeQueue) RecordWebhookAttempt(ctx context.Context, arg database.RecordWebhookAttemptParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.status[arg.ID] = arg.Status
+	q.dueAt[arg.ID] = arg.NextAttemptAt
+	return nil
+}
+
+func TestDeliverDueSlowReceiver(t *testing.T) {
+	slow := database.ClaimWebhookDeliveriesRow{ID: uuid.New(), Event: EventChirpCreated, Payload: []byte(`{}`), AllUsers: true}
+	others := []database.ClaimWebhookDeliveriesRow{
+		{ID: uuid.New(), Event: EventChirpCreated, Payload: []byte(`{}`), AllUsers: true},
+		{ID: uuid.New(), Event: EventChirpCreated, Payload: []byte(`{}`), AllUsers: true},
+	}
+
+	var mu sync.Mutex
+	received := map[string]int{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(DeliveryHeader)
+		mu.Lock()
+		received[id]++
+		mu.Unlock()
+		if id == slow.ID.String() {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	slow.Url = receiver.URL
+	for i := range others {
+		others[i].Url = receiver.URL
+	}
+	queue := newFakeQueue(append([]database.ClaimWebhookDeliveriesRow{slow}, others...)...)
+
+	// One instance gets stuck on the slow receiver; another polls meanwhile.
+	first := NewDispatcher(queue)
+	firstErr := make(chan error)
+	go func() { firstErr <- first.DeliverDue(context.Background()) }()
+	<-started
+
+	if err := NewDispatcher(queue).DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	for _, delivery := range others {
+		if queue.status[delivery.ID] != StatusDelivered {
+			t.Errorf("delivery %s is %s while the slow one is in flight, want %s", delivery.ID, queue.status[delivery.ID], StatusDelivered)
+		}
+	}
+
+	close(release)
+	if err := <-firstErr; err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	if queue.status[slow.ID] != StatusDelivered {
+		t.Errorf("slow delivery is %s, want %s", queue.status[slow.ID], StatusDelivered)
+	}
+	for id, count := range received {
+		if count != 1 {
+			t.Errorf("delivery %s received %d times, want 1", id, count)
+		}
+	}
+	if len(received) != 3 {
+		t.Errorf("received %d deliveries, want 3", len(received))
+	}
+}
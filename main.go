@@ -8,12 +8,20 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"strconv"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/BradDeA/chirpy.git/internal/auth"
 	"github.com/BradDeA/chirpy.git/internal/database"
+	"github.com/BradDeA/chirpy.git/internal/oidc"
+	"github.com/BradDeA/chirpy.git/internal/realtime"
+	"github.com/BradDeA/chirpy.git/internal/stream"
+	"github.com/BradDeA/chirpy.git/internal/timeline"
+	"github.com/BradDeA/chirpy.git/internal/trends"
+	"github.com/BradDeA/chirpy.git/internal/webhooks"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -22,12 +30,23 @@ import (
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	Db             *database.Queries
+	DBConn         *sql.DB
 	SecretKey      string
+	Auth           *auth.Authenticator
+	AccessTokenTTL time.Duration
+	ChirpRetention time.Duration
+	Identity       oidc.IdentityProvider
+	Timeline       *timeline.Fanout
+	TrendWindows   []trends.Window
+	Stream         *stream.Hub
+	Notifications  *stream.Hub
+	Sockets        *realtime.Registry
 }
 
 type RequestParams struct {
-	Body    string    `json:"body"`
-	User_id uuid.UUID `json:"user_id"`
+	Body        string     `json:"body"`
+	User_id     uuid.UUID  `json:"user_id"`
+	Reply_to_id *uuid.UUID `json:"reply_to_id"`
 }
 
 type UserValues struct {
@@ -35,17 +54,74 @@ type UserValues struct {
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	Username     string    `json:"username"`
+	DisplayName  string    `json:"display_name"`
+	Bio          string    `json:"bio"`
+	AvatarURL    string    `json:"avatar_url"`
 	Password     string    `json:"-"`
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"`
 }
 
 type ChirpRes struct {
-	Id         uuid.UUID `json:"id"`
-	Created_at time.Time `json:"created_at"`
-	Updated_at time.Time `json:"updated_at"`
-	Body       string    `json:"body"`
-	User_id    uuid.UUID `json:"user_id"`
+	Id           uuid.UUID  `json:"id"`
+	Created_at   time.Time  `json:"created_at"`
+	Updated_at   time.Time  `json:"updated_at"`
+	Body         string     `json:"body"`
+	User_id      uuid.UUID  `json:"user_id"`
+	Reply_to_id  *uuid.UUID `json:"reply_to_id"`
+	Reply_count  int64      `json:"reply_count"`
+	Like_count   int64      `json:"like_count"`
+	Liked_by_me  *bool      `json:"liked_by_me,omitempty"`
+	Repost_of_id *uuid.UUID `json:"repost_of_id"`
+	Repost_of    *ChirpRes  `json:"repost_of,omitempty"`
+
+	Author_username     string      `json:"author_username"`
+	Author_display_name string      `json:"author_display_name"`
+	Entities            []EntityRes `json:"entities"`
+}
+
+// makeAccessToken issues an access token for user. authTime is when the user
+// signed in, or zero for tokens issued from a refresh token.
+func (cfg *apiConfig) makeAccessToken(user database.User, authTime time.Time) (string, error) {
+	return auth.MakeJWT(user.ID, cfg.SecretKey, auth.TokenOptions{
+		TTL:      cfg.AccessTokenTTL,
+		Audience: cfg.Auth.Options.Audience,
+		Role:     user.Role,
+		AuthTime: authTime,
+	})
+}
+
+// createSession issues a new access and refresh token pair for user, as
+// returned by POST /api/login.
+func (cfg *apiConfig) createSession(ctx context.Context, user database.User) (UserValues, error) {
+	token, err := cfg.makeAccessToken(user, time.Now())
+	if err != nil {
+		return UserValues{}, err
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return UserValues{}, err
+	}
+
+	_, err = cfg.Db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     refreshToken,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(60 * 24 * time.Hour),
+		RevokedAt: sql.NullTime{Valid: false},
+	})
+	if err != nil {
+		return UserValues{}, err
+	}
+
+	values := userValues(user)
+	values.Token = token
+	values.RefreshToken = refreshToken
+	return values, nil
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -82,6 +158,120 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Hits reset to 0"))
 }
 
+func (cfg *apiConfig) handlerSetRole(w http.ResponseWriter, r *http.Request) {
+	userID, parseErr := uuid.Parse(r.PathValue("userID"))
+	if parseErr != nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	params := struct {
+		Role string `json:"role"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil || !auth.ValidRole(params.Role) {
+		w.WriteHeader(400)
+		return
+	}
+
+	_, lookupErr := cfg.Db.GetUser(r.Context(), userID)
+	if lookupErr == sql.ErrNoRows {
+		w.WriteHeader(404)
+		return
+	}
+	if lookupErr != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	updateErr := cfg.Db.SetUserRole(r.Context(), database.SetUserRoleParams{Role: params.Role, ID: userID})
+	if updateErr != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerModerationLog(w http.ResponseWriter, r *http.Request) {
+	type ModerationRes struct {
+		Id            uuid.UUID  `json:"id"`
+		CreatedAt     time.Time  `json:"created_at"`
+		ModeratorID   *uuid.UUID `json:"moderator_id"`
+		Action        string     `json:"action"`
+		ChirpID       uuid.UUID  `json:"chirp_id"`
+		ChirpAuthorID uuid.UUID  `json:"chirp_author_id"`
+		ChirpBody     string     `json:"chirp_body"`
+	}
+
+	actions, err := cfg.Db.GetModerationActions(r.Context())
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	res := []ModerationRes{}
+	for _, action := range actions {
+		entry := ModerationRes{
+			Id:            action.ID,
+			CreatedAt:     action.CreatedAt,
+			Action:        action.Action,
+			ChirpID:       action.ChirpID,
+			ChirpAuthorID: action.ChirpAuthorID,
+			ChirpBody:     action.ChirpBody,
+		}
+		if action.ModeratorID.Valid {
+			entry.ModeratorID = &action.ModeratorID.UUID
+		}
+		res = append(res, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(res)
+}
+
+const (
+	moderationDeleteChirp  = "delete_chirp"
+	moderationRestoreChirp = "restore_chirp"
+)
+
+// moderateChirp deletes or restores someone else's chirp on behalf of a
+// moderator and records who did what in the same transaction. It returns
+// every chirp changed, which includes plain rechirps of the chirp.
+func (cfg *apiConfig) moderateChirp(ctx context.Context, moderatorID uuid.UUID, action string, chirp database.Chirp) ([]database.Chirp, error) {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.Db.WithTx(tx)
+	_, err = qtx.CreateModerationAction(ctx, database.CreateModerationActionParams{
+		ModeratorID:   uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:        action,
+		ChirpID:       chirp.ID,
+		ChirpAuthorID: chirp.UserID,
+		ChirpBody:     chirp.Body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var changed []database.Chirp
+	if action == moderationRestoreChirp {
+		changed, err = qtx.RestoreChirp(ctx, chirp.ID)
+	} else {
+		changed, err = qtx.SoftDeleteChirp(ctx, chirp.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return changed, tx.Commit()
+}
+
+// shutdownTimeout bounds how long in-flight requests get to finish once the
+// server is told to stop.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 
 	godotenv.Load()
@@ -90,18 +280,133 @@ func main() {
 	server := http.Server{Addr: ":8080", Handler: ServMux}
 	secretString := os.Getenv("SECRET")
 
+	var leeway time.Duration
+	if leewayString := os.Getenv("JWT_LEEWAY"); leewayString != "" {
+		parsed, err := time.ParseDuration(leewayString)
+		if err != nil {
+			log.Fatalf("invalid JWT_LEEWAY: %v", err)
+		}
+		leeway = parsed
+	}
+
+	accessTokenTTL := auth.DefaultTokenTTL
+	if ttlString := os.Getenv("ACCESS_TOKEN_TTL"); ttlString != "" {
+		parsed, err := time.ParseDuration(ttlString)
+		if err != nil {
+			log.Fatalf("invalid ACCESS_TOKEN_TTL: %v", err)
+		}
+		accessTokenTTL = parsed
+	}
+
+	chirpRetention := defaultChirpRetention
+	if retentionString := os.Getenv("CHIRP_RETENTION"); retentionString != "" {
+		parsed, err := time.ParseDuration(retentionString)
+		if err != nil {
+			log.Fatalf("invalid CHIRP_RETENTION: %v", err)
+		}
+		chirpRetention = parsed
+	}
+
 	db, dberr := sql.Open("postgres", dbURL)
 	if dberr != nil {
 		log.Println(dberr)
 	}
 
 	dbQueries := database.New(db)
-	apiCfg := apiConfig{Db: dbQueries, SecretKey: secretString}
+
+	var denylist auth.Denylist
+	switch os.Getenv("ACCESS_TOKEN_DENYLIST") {
+	case "", "memory":
+		denylist = auth.NewMemoryDenylist()
+	case "postgres":
+		denylist = auth.NewDBDenylist(dbQueries)
+	default:
+		log.Fatalf("invalid ACCESS_TOKEN_DENYLIST: %q", os.Getenv("ACCESS_TOKEN_DENYLIST"))
+	}
+	go auth.RunDenylistPurger(context.Background(), denylist, 5*time.Minute)
+
+	apiCfg := apiConfig{
+		Db:        dbQueries,
+		DBConn:    db,
+		SecretKey: secretString,
+		Auth: &auth.Authenticator{
+			Secret:         secretString,
+			Options:        auth.ValidateOptions{Audience: os.Getenv("JWT_AUDIENCE"), Leeway: leeway},
+			Denylist:       denylist,
+			PersonalTokens: auth.NewDBPersonalTokens(dbQueries),
+		},
+		AccessTokenTTL: accessTokenTTL,
+		ChirpRetention: chirpRetention,
+	}
+	go runChirpPurger(context.Background(), dbQueries, chirpRetention, time.Hour)
+
+	trendWindowsString := os.Getenv("TREND_WINDOWS")
+	if trendWindowsString == "" {
+		trendWindowsString = trends.DefaultWindows
+	}
+	trendWindows, trendErr := trends.ParseWindows(trendWindowsString)
+	if trendErr != nil {
+		log.Fatalf("invalid TREND_WINDOWS: %q", trendWindowsString)
+	}
+	trendInterval := trends.DefaultInterval
+	if intervalString := os.Getenv("TREND_INTERVAL"); intervalString != "" {
+		parsed, err := time.ParseDuration(intervalString)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("invalid TREND_INTERVAL: %q", intervalString)
+		}
+		trendInterval = parsed
+	}
+	apiCfg.TrendWindows = trendWindows
+	go trends.NewAggregator(dbQueries, db, trendWindows, profaneWords).Run(context.Background(), trendInterval)
+
+	if os.Getenv("TIMELINE_FANOUT") != "off" {
+		threshold := int64(timeline.DefaultThreshold)
+		if thresholdString := os.Getenv("TIMELINE_FANOUT_THRESHOLD"); thresholdString != "" {
+			parsed, err := strconv.ParseInt(thresholdString, 10, 64)
+			if err != nil || parsed < 0 {
+				log.Fatalf("invalid TIMELINE_FANOUT_THRESHOLD: %q", thresholdString)
+			}
+			threshold = parsed
+		}
+		apiCfg.Timeline = timeline.NewFanout(dbQueries, db, threshold, timeline.DefaultQueueSize)
+		go apiCfg.Timeline.Run(context.Background())
+
+		ServMux.Handle("POST /admin/timelines/{userID}/rebuild", apiCfg.Auth.RequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.handlerRebuildTimeline)))
+		ServMux.Handle("GET /admin/timelines/{userID}/check", apiCfg.Auth.RequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.handlerCheckTimeline)))
+	}
+
+	apiCfg.Stream = stream.NewHub(stream.DefaultReplaySize)
+	go apiCfg.Stream.Listen(context.Background(), newStreamListener(dbURL, stream.Channel))
+	// Nothing resumes a notification stream, so there is no replay buffer.
+	apiCfg.Notifications = stream.NewHub(0)
+	go apiCfg.Notifications.Listen(context.Background(), newStreamListener(dbURL, stream.NotificationChannel))
+	apiCfg.Sockets = realtime.NewRegistry()
+
+	go webhooks.NewDispatcher(dbQueries).Run(context.Background(), webhooks.DefaultPollInterval)
+
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		}, nil)
+		if err != nil {
+			log.Fatalf("configuring OIDC login: %v", err)
+		}
+		apiCfg.Identity = provider
+
+		ServMux.HandleFunc("GET /api/auth/oidc/start", apiCfg.handlerOIDCStart)
+		ServMux.HandleFunc("GET /api/auth/oidc/callback", apiCfg.handlerOIDCCallback)
+	}
 
 	ServMux.Handle("GET /app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
 	ServMux.Handle("GET /assets", apiCfg.middlewareMetricsInc(http.FileServer(http.Dir("."))))
-	ServMux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
-	ServMux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	ServMux.Handle("GET /admin/metrics", apiCfg.Auth.RequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.handlerMetrics)))
+	ServMux.Handle("POST /admin/reset", apiCfg.Auth.RequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.handlerReset)))
+	ServMux.Handle("PUT /admin/users/{userID}/role", apiCfg.Auth.RequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.handlerSetRole)))
+	ServMux.Handle("POST /admin/webhooks", apiCfg.Auth.RequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.handlerCreateAdminWebhook)))
+	ServMux.Handle("GET /admin/moderation", apiCfg.Auth.RequireRole(auth.RoleModerator, http.HandlerFunc(apiCfg.handlerModerationLog)))
 
 	ServMux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type:", "text/plain; charset=utf-8")
@@ -110,9 +415,8 @@ func main() {
 
 	})
 
-	ServMux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+	ServMux.Handle("POST /api/chirps", apiCfg.Auth.RequireScope(auth.ScopeChirpsWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		profane := []string{"kerfuffle", "sharbert", "fornax"}
 		decoder := json.NewDecoder(r.Body)
 		jsonParams := RequestParams{}
 		err := decoder.Decode(&jsonParams)
@@ -121,57 +425,41 @@ func main() {
 			return
 		}
 
-		token, tokenErr := auth.GetBearerToken(r.Header)
-		if tokenErr != nil {
-			w.WriteHeader(401)
-			return
-		}
+		claims, _ := auth.UserFromContext(r.Context())
 
-		tokenValid, tokenErr := auth.ValidateJWT(token, secretString)
-		if tokenErr != nil {
-			w.WriteHeader(401)
+		if len(jsonParams.Body) > maxChirpLength {
+			writeChirpTooLong(w)
 			return
 		}
+		joined := cleanChirpBody(jsonParams.Body)
 
-		if len(jsonParams.Body) > 140 {
-			values := ChirpRes{Body: "Chirp is too long"}
-			data, err := json.Marshal(values)
-			if err != nil {
-				w.WriteHeader(500)
+		replyTo := uuid.NullUUID{}
+		if jsonParams.Reply_to_id != nil {
+			parent, parentErr := apiCfg.Db.GetChirp(r.Context(), *jsonParams.Reply_to_id)
+			if parentErr == sql.ErrNoRows {
+				w.WriteHeader(400)
 				return
 			}
-
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(400)
-			w.Write(data)
-			return
-		}
-		words := strings.Split(jsonParams.Body, " ")
-
-		for i, word := range words {
-			lowerWord := strings.ToLower(word)
-			for _, profaneWord := range profane {
-				if lowerWord == profaneWord {
-					words[i] = "****"
-				}
+			if parentErr != nil {
+				w.WriteHeader(500)
+				return
 			}
+			replyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
 		}
-		joined := strings.Join(words, " ")
 
-		chirp, createErr := apiCfg.Db.CreateChirp(context.Background(), database.CreateChirpParams{Body: joined, UserID: tokenValid})
+		chirp, createErr := apiCfg.createChirp(r.Context(), database.CreateChirpParams{Body: joined, UserID: claims.UserID, ReplyToID: replyTo})
 		if createErr != nil {
 			w.WriteHeader(500)
 			w.Write([]byte(createErr.Error()))
 			return
 		}
-		res := ChirpRes{
-			Id:         chirp.ID,
-			Created_at: chirp.CreatedAt,
-			Updated_at: chirp.UpdatedAt,
-			Body:       chirp.Body,
-			User_id:    tokenValid,
+		apiCfg.chirpCreated(r.Context(), chirp)
+		res, resErr := apiCfg.chirpResponses(r.Context(), []database.Chirp{chirp})
+		if resErr != nil {
+			w.WriteHeader(500)
+			return
 		}
-		marshal, err := json.Marshal(res)
+		marshal, err := json.Marshal(res[0])
 		if err != nil {
 			w.WriteHeader(500)
 			return
@@ -180,13 +468,14 @@ func main() {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(201)
 		w.Write(marshal)
-	})
+	})))
 
 	ServMux.HandleFunc("POST /api/users", func(w http.ResponseWriter, r *http.Request) {
 
 		type JsonBody struct {
 			Email    string `json:"email"`
 			Password string `json:"password"`
+			Username string `json:"username"`
 		}
 
 		decoder := json.NewDecoder(r.Body)
@@ -197,18 +486,45 @@ func main() {
 			fmt.Print(err)
 			return
 		}
+		if params.Username == "" {
+			generated, genErr := generateUsername()
+			if genErr != nil {
+				w.WriteHeader(500)
+				return
+			}
+			params.Username = generated
+		} else {
+			if !usernamePattern.MatchString(params.Username) {
+				w.WriteHeader(400)
+				return
+			}
+			taken, takenErr := apiCfg.usernameTaken(r, params.Username, uuid.Nil)
+			if takenErr != nil {
+				w.WriteHeader(500)
+				return
+			}
+			if taken {
+				w.WriteHeader(409)
+				return
+			}
+		}
 		hash, hashErr := auth.HashPassword(params.Password)
 		if hashErr != nil {
 			w.WriteHeader(500)
 			return
 		}
-		user, userErr := apiCfg.Db.CreateUser(context.Background(), database.CreateUserParams{Email: params.Email, HashedPassword: hash})
+		user, userErr := apiCfg.Db.CreateUser(context.Background(), database.CreateUserParams{Email: params.Email, HashedPassword: hash, Username: params.Username})
+		if isUniqueViolation(userErr) {
+			w.WriteHeader(409)
+			return
+		}
 		if userErr != nil {
 			w.WriteHeader(500)
 			return
 		}
+		apiCfg.userCreated(r.Context(), user)
 
-		marshalValues := UserValues{Id: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email}
+		marshalValues := userValues(user)
 		returnData, marshalErr := json.Marshal(marshalValues)
 		if marshalErr != nil {
 			w.WriteHeader(500)
@@ -221,16 +537,16 @@ func main() {
 
 	})
 
-	ServMux.HandleFunc("GET /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+	ServMux.Handle("GET /api/chirps", apiCfg.Auth.OptionalAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		allChirps, err := apiCfg.Db.GetChirps(context.Background())
 		if err != nil {
 			w.WriteHeader(500)
 			return
 		}
-		chirpStructs := []ChirpRes{}
-
-		for _, chirp := range allChirps {
-			chirpStructs = append(chirpStructs, ChirpRes{Id: chirp.ID, Created_at: chirp.CreatedAt, Updated_at: chirp.UpdatedAt, Body: chirp.Body, User_id: chirp.UserID})
+		chirpStructs, resErr := apiCfg.chirpResponses(r.Context(), allChirps)
+		if resErr != nil {
+			w.WriteHeader(500)
+			return
 		}
 
 		chirps, chirpErr := json.Marshal(chirpStructs)
@@ -242,36 +558,31 @@ func main() {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(200)
 		w.Write(chirps)
-	})
+	})))
 
-	ServMux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
-		allChirps, err := apiCfg.Db.GetChirps(context.Background())
-		if err != nil {
-			w.WriteHeader(500)
+	ServMux.Handle("GET /api/chirps/{chirpID}", apiCfg.Auth.OptionalAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+		if parseErr != nil {
+			w.WriteHeader(404)
 			return
 		}
 
-		var chirpStructs ChirpRes
-		found := false
-
-		for _, chirp := range allChirps {
-			if r.PathValue("chirpID") == chirp.ID.String() {
-				found = true
-				chirpStructs = ChirpRes{
-					Id:         chirp.ID,
-					Created_at: chirp.CreatedAt,
-					Updated_at: chirp.UpdatedAt,
-					Body:       chirp.Body,
-					User_id:    chirp.UserID,
-				}
-				break
-			}
+		chirp, err := apiCfg.Db.GetChirp(r.Context(), chirpID)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(404)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			return
 		}
 
-		if !found {
-			w.WriteHeader(404)
+		res, resErr := apiCfg.chirpResponses(r.Context(), []database.Chirp{chirp})
+		if resErr != nil {
+			w.WriteHeader(500)
 			return
 		}
+		chirpStructs := res[0]
 
 		chirps, chirpErr := json.Marshal(chirpStructs)
 		if chirpErr != nil {
@@ -282,7 +593,7 @@ func main() {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(200)
 		w.Write(chirps)
-	})
+	})))
 
 	ServMux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
 		type ValidReq struct {
@@ -312,35 +623,13 @@ func main() {
 			return
 		}
 
-		token, tokenErr := auth.MakeJWT(found.ID, secretString)
-		if tokenErr != nil {
-			w.WriteHeader(500)
-			fmt.Println("makejwt error")
-			return
-		}
-
-		refresh_token, tokenErr := auth.MakeRefreshToken()
-		if tokenErr != nil {
-			w.WriteHeader(500)
-			fmt.Println("refresh token error")
-			return
-		}
-
-		_, createErr := apiCfg.Db.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
-			Token:     refresh_token,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-			UserID:    found.ID,
-			ExpiresAt: time.Now().Add(60 * 24 * time.Hour),
-			RevokedAt: sql.NullTime{Valid: false},
-		})
-		if createErr != nil {
+		marshalValues, sessionErr := apiCfg.createSession(r.Context(), found)
+		if sessionErr != nil {
 			w.WriteHeader(500)
-			fmt.Println("create refesh token error", createErr)
+			fmt.Println("create session error", sessionErr)
 			return
 		}
 
-		marshalValues := UserValues{Id: found.ID, CreatedAt: found.CreatedAt, UpdatedAt: found.UpdatedAt, Email: found.Email, Token: token, RefreshToken: refresh_token}
 		returnData, marshalErr := json.Marshal(marshalValues)
 		if marshalErr != nil {
 			w.WriteHeader(500)
@@ -365,7 +654,7 @@ func main() {
 			return
 		}
 
-		accessToken, err := auth.MakeJWT(user.ID, secretString)
+		accessToken, err := apiCfg.makeAccessToken(user, time.Time{})
 		if err != nil {
 			w.WriteHeader(500)
 			return
@@ -400,18 +689,24 @@ func main() {
 		w.WriteHeader(204)
 	})
 
-	ServMux.HandleFunc("PUT /api/users", func(w http.ResponseWriter, r *http.Request) {
-		token, gettokenErr := auth.GetBearerToken(r.Header)
-		if gettokenErr != nil {
-			w.WriteHeader(401)
+	ServMux.Handle("POST /api/logout", apiCfg.Auth.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := auth.UserFromContext(r.Context())
+		if claims.Personal {
+			w.WriteHeader(400)
 			return
 		}
 
-		user, getuserErr := auth.ValidateJWT(token, secretString)
-		if getuserErr != nil {
-			w.WriteHeader(401)
+		denyErr := apiCfg.Auth.Denylist.Add(r.Context(), claims.ID, claims.ExpiresAt.Time)
+		if denyErr != nil {
+			w.WriteHeader(500)
+			fmt.Println("denylist error", denyErr)
 			return
 		}
+		w.WriteHeader(204)
+	})))
+
+	ServMux.Handle("PUT /api/users", apiCfg.Auth.RequireScope(auth.ScopeProfileWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := auth.UserFromContext(r.Context())
 
 		type ValidReq struct {
 			Email    string `json:"email"`
@@ -430,7 +725,7 @@ func main() {
 			w.WriteHeader(500)
 			return
 		}
-		updateErr := apiCfg.Db.UpdateUser(context.Background(), database.UpdateUserParams{Email: params.Email, HashedPassword: pword, ID: user})
+		updateErr := apiCfg.Db.UpdateUser(context.Background(), database.UpdateUserParams{Email: params.Email, HashedPassword: pword, ID: claims.UserID})
 		if updateErr != nil {
 			w.WriteHeader(500)
 			return
@@ -440,7 +735,7 @@ func main() {
 			w.WriteHeader(500)
 			return
 		}
-		marshalValues := UserValues{Id: record.ID, CreatedAt: record.CreatedAt, UpdatedAt: record.UpdatedAt, Email: record.Email}
+		marshalValues := userValues(record)
 		returnData, marshalErr := json.Marshal(marshalValues)
 		if marshalErr != nil {
 			w.WriteHeader(500)
@@ -452,47 +747,131 @@ func main() {
 		w.WriteHeader(200)
 		w.Write(returnData)
 
-	})
+	})))
 
-	ServMux.HandleFunc("DELETE /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
-		token, gettokenErr := auth.GetBearerToken(r.Header)
-		if gettokenErr != nil {
-			w.WriteHeader(401)
+	ServMux.Handle("DELETE /api/chirps/{chirpID}", apiCfg.Auth.RequireScope(auth.ScopeChirpsWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := auth.UserFromContext(r.Context())
+
+		chirpID, parseErr := uuid.Parse(r.PathValue("chirpID"))
+		if parseErr != nil {
+			w.WriteHeader(404)
 			return
 		}
-		userID, getuserErr := auth.ValidateJWT(token, secretString)
-		if getuserErr != nil {
-			w.WriteHeader(401)
+
+		chirp, err := apiCfg.Db.GetChirp(r.Context(), chirpID)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(404)
 			return
 		}
-
-		allChirps, err := apiCfg.Db.GetChirps(context.Background())
 		if err != nil {
 			w.WriteHeader(500)
 			return
 		}
 
-		for _, chirp := range allChirps {
-			if r.PathValue("chirpID") == chirp.ID.String() {
-				if chirp.UserID != userID {
-					w.WriteHeader(403)
-					return
-				}
-				deleteErr := apiCfg.Db.DeleteChirp(context.Background(), chirp.ID)
-				if deleteErr != nil {
-					w.WriteHeader(401)
-					return
-				}
-
+		// Plain rechirps of the chirp are deleted along with it.
+		var deleted []database.Chirp
+		if chirp.UserID == claims.UserID {
+			var deleteErr error
+			deleted, deleteErr = apiCfg.Db.SoftDeleteChirp(r.Context(), chirp.ID)
+			if deleteErr != nil {
+				w.WriteHeader(500)
+				return
 			}
+		} else if claims.HasRole(auth.RoleModerator) {
+			var moderateErr error
+			deleted, moderateErr = apiCfg.moderateChirp(r.Context(), claims.UserID, moderationDeleteChirp, chirp)
+			if moderateErr != nil {
+				w.WriteHeader(500)
+				fmt.Println("moderation error", moderateErr)
+				return
+			}
+		} else {
+			w.WriteHeader(403)
+			return
+		}
+		for _, deletedChirp := range deleted {
+			apiCfg.chirpDeleted(r.Context(), deletedChirp)
 		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(204)
-	})
+	})))
+
+	ServMux.Handle("PATCH /api/chirps/{chirpID}", apiCfg.Auth.RequireScope(auth.ScopeChirpsWrite, http.HandlerFunc(apiCfg.handlerEditChirp)))
+	ServMux.Handle("GET /api/chirps/{chirpID}/thread", apiCfg.Auth.OptionalAuth(http.HandlerFunc(apiCfg.handlerChirpThread)))
+	ServMux.Handle("PUT /api/chirps/{chirpID}/like", apiCfg.Auth.RequireScope(auth.ScopeChirpsWrite, http.HandlerFunc(apiCfg.handlerLikeChirp)))
+	ServMux.Handle("DELETE /api/chirps/{chirpID}/like", apiCfg.Auth.RequireScope(auth.ScopeChirpsWrite, http.HandlerFunc(apiCfg.handlerUnlikeChirp)))
+	ServMux.HandleFunc("GET /api/chirps/{chirpID}/likes", apiCfg.handlerChirpLikes)
+	ServMux.Handle("POST /api/chirps/{chirpID}/rechirp", apiCfg.Auth.RequireScope(auth.ScopeChirpsWrite, http.HandlerFunc(apiCfg.handlerRechirp)))
+	ServMux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handlerChirpRevisions)
+	ServMux.Handle("POST /api/chirps/{chirpID}/restore", apiCfg.Auth.RequireScope(auth.ScopeChirpsWrite, http.HandlerFunc(apiCfg.handlerRestoreChirp)))
+
+	ServMux.Handle("PUT /api/users/{userID}/follow", apiCfg.Auth.RequireScope(auth.ScopeProfileWrite, http.HandlerFunc(apiCfg.handlerFollowUser)))
+	ServMux.Handle("DELETE /api/users/{userID}/follow", apiCfg.Auth.RequireScope(auth.ScopeProfileWrite, http.HandlerFunc(apiCfg.handlerUnfollowUser)))
+	ServMux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.handlerFollowers)
+	ServMux.HandleFunc("GET /api/users/{userID}/following", apiCfg.handlerFollowing)
+	ServMux.Handle("GET /api/timeline", apiCfg.Auth.RequireScope(auth.ScopeChirpsRead, http.HandlerFunc(apiCfg.handlerTimeline)))
+
+	ServMux.HandleFunc("GET /api/users/{username}", apiCfg.handlerGetProfile)
+	ServMux.Handle("GET /api/users/me/mentions", apiCfg.Auth.RequireScope(auth.ScopeChirpsRead, http.HandlerFunc(apiCfg.handlerMyMentions)))
+	ServMux.HandleFunc("GET /api/trends", apiCfg.handlerTrends)
+	ServMux.Handle("GET /api/hashtags/{tag}/chirps", apiCfg.Auth.OptionalAuth(http.HandlerFunc(apiCfg.handlerHashtagChirps)))
+	ServMux.Handle("GET /api/search/chirps", apiCfg.Auth.OptionalAuth(http.HandlerFunc(apiCfg.handlerSearchChirps)))
+	ServMux.HandleFunc("GET /api/search/users", apiCfg.handlerSearchUsers)
+	ServMux.HandleFunc("GET /api/stream/chirps", apiCfg.handlerStreamChirps)
+	ServMux.Handle("GET /api/ws", apiCfg.Auth.RequireScope(auth.ScopeChirpsRead, http.HandlerFunc(apiCfg.handlerWebSocket)))
+	ServMux.Handle("GET /api/notifications", apiCfg.Auth.RequireScope(auth.ScopeChirpsRead, http.HandlerFunc(apiCfg.handlerNotifications)))
+	ServMux.Handle("POST /api/notifications/read", apiCfg.Auth.RequireScope(auth.ScopeProfileWrite, http.HandlerFunc(apiCfg.handlerReadNotifications)))
+	ServMux.Handle("GET /api/notifications/preferences", apiCfg.Auth.RequireScope(auth.ScopeChirpsRead, http.HandlerFunc(apiCfg.handlerGetNotificationPreferences)))
+	ServMux.Handle("PUT /api/notifications/preferences", apiCfg.Auth.RequireScope(auth.ScopeProfileWrite, http.HandlerFunc(apiCfg.handlerUpdateNotificationPreferences)))
+	ServMux.Handle("PATCH /api/users/me", apiCfg.Auth.RequireScope(auth.ScopeProfileWrite, http.HandlerFunc(apiCfg.handlerUpdateProfile)))
+
+	ServMux.Handle("DELETE /api/users", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerDeleteAccount)))
+	ServMux.Handle("GET /api/users/me/export", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerExportAccount)))
+
+	ServMux.Handle("POST /api/tokens", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerCreatePersonalToken)))
+	ServMux.Handle("GET /api/tokens", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerListPersonalTokens)))
+	ServMux.Handle("DELETE /api/tokens/{tokenID}", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerDeletePersonalToken)))
+
+	ServMux.Handle("POST /api/webhooks", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerCreateWebhook)))
+	ServMux.Handle("GET /api/webhooks", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerListWebhooks)))
+	ServMux.Handle("DELETE /api/webhooks/{webhookID}", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerDeleteWebhook)))
+	ServMux.Handle("GET /api/webhooks/{webhookID}/deliveries", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerWebhookDeliveries)))
+	ServMux.Handle("POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/retry", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerRetryWebhookDelivery)))
+
+	ServMux.Handle("POST /api/oauth/clients", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerCreateOAuthClient)))
+	ServMux.Handle("GET /api/oauth/clients", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerListOAuthClients)))
+	ServMux.Handle("DELETE /api/oauth/clients/{clientID}", apiCfg.Auth.RequireAuth(http.HandlerFunc(apiCfg.handlerDeleteOAuthClient)))
+	ServMux.HandleFunc("GET /oauth/authorize", apiCfg.handlerOAuthAuthorize)
+	ServMux.HandleFunc("POST /oauth/authorize", apiCfg.handlerOAuthAuthorizeSubmit)
+	ServMux.HandleFunc("POST /oauth/token", apiCfg.handlerOAuthToken)
+	ServMux.HandleFunc("POST /oauth/introspect", apiCfg.handlerOAuthIntrospect)
+	ServMux.HandleFunc("POST /oauth/revoke", apiCfg.handlerOAuthRevoke)
+
+	// On SIGINT or SIGTERM, stop accepting connections, say goodbye to
+	// WebSocket clients, end open event streams and let in-flight requests
+	// finish before exiting.
+	server.RegisterOnShutdown(apiCfg.Sockets.Shutdown)
+	server.RegisterOnShutdown(apiCfg.Stream.Shutdown)
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-signals.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		apiCfg.Sockets.Wait()
+	}()
 
 	err := server.ListenAndServe()
-	if err != nil {
+	if err != nil && err != http.ErrServerClosed {
 		fmt.Print(err)
+		return
 	}
-
+	<-stopped
 }
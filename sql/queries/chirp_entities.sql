@@ -0,0 +1,38 @@
+-- name: CreateChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, handle, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions WHERE chirp_id = $1;
+
+-- name: GetMentionsForChirps :many
+SELECT * FROM chirp_mentions WHERE chirp_id = ANY(@chirp_ids::uuid[]);
+
+-- name: GetMentionedChirps :many
+SELECT chirps.* FROM chirp_mentions
+JOIN chirps ON chirps.id = chirp_mentions.chirp_id
+WHERE chirp_mentions.user_id = @user_id
+  AND chirps.deleted_at IS NULL
+  AND (sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (chirp_mentions.created_at, chirp_mentions.chirp_id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid))
+ORDER BY chirp_mentions.created_at DESC, chirp_mentions.chirp_id DESC
+LIMIT @max_results;
+
+-- name: CreateChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags WHERE chirp_id = $1;
+
+-- name: GetChirpsByHashtag :many
+SELECT chirps.* FROM chirp_hashtags
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirp_hashtags.tag = @tag
+  AND chirps.deleted_at IS NULL
+  AND (sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (chirp_hashtags.created_at, chirp_hashtags.chirp_id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid))
+ORDER BY chirp_hashtags.created_at DESC, chirp_hashtags.chirp_id DESC
+LIMIT @max_results;
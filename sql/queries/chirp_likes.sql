@@ -0,0 +1,27 @@
+-- name: LikeChirp :exec
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM chirp_likes WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetChirpLikes :many
+SELECT * FROM chirp_likes
+WHERE chirp_id = @chirp_id
+  AND (sqlc.narg(after_created_at)::timestamp IS NULL
+    OR (created_at, user_id) > (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_user_id)::uuid))
+ORDER BY created_at, user_id
+LIMIT @max_results;
+
+-- name: CountLikesForChirps :many
+SELECT chirp_id, COUNT(*) AS like_count FROM chirp_likes
+WHERE chirp_id = ANY(@chirp_ids::uuid[])
+GROUP BY chirp_id;
+
+-- name: GetLikedChirpIDs :many
+SELECT chirp_id FROM chirp_likes
+WHERE user_id = @user_id AND chirp_id = ANY(@chirp_ids::uuid[]);
+
+-- name: GetLikesByUser :many
+SELECT * FROM chirp_likes WHERE user_id = $1 ORDER BY created_at;
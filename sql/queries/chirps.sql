@@ -1,16 +1,118 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, repost_of_id)
 VALUES (
     gen_random_uuid (),
     NOW(),
     NOW(),
     $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, repost_of_id)
+VALUES (
+    gen_random_uuid (),
+    NOW(),
+    NOW(),
+    '',
+    $1,
     $2
 )
+ON CONFLICT (user_id, repost_of_id) WHERE body = '' AND deleted_at IS NULL DO NOTHING
 RETURNING *;
 
 -- name: GetChirps :many
-SELECT * FROM chirps ORDER BY created_at;
+SELECT * FROM chirps WHERE deleted_at IS NULL ORDER BY created_at;
+
+-- name: GetChirp :one
+SELECT * FROM chirps WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetChirpForUpdate :one
+SELECT * FROM chirps WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps WHERE id = ANY(@ids::uuid[]) AND deleted_at IS NULL;
+
+-- name: GetChirpsForUser :many
+SELECT * FROM chirps WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at;
+
+-- name: GetChirpsDeletedWithUser :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (user_id = @user_id
+    OR (body = '' AND repost_of_id IN (SELECT id FROM chirps WHERE user_id = @user_id)));
+
+-- name: SoftDeleteChirp :many
+UPDATE chirps SET deleted_at = NOW()
+WHERE deleted_at IS NULL AND (id = @id OR (repost_of_id = @id AND body = ''))
+RETURNING *;
+
+-- name: GetDeletedChirp :one
+SELECT * FROM chirps WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: RestoreChirp :many
+UPDATE chirps SET deleted_at = NULL
+WHERE id = @id
+   OR (repost_of_id = @id AND body = ''
+       AND deleted_at = (SELECT deleted_at FROM chirps WHERE id = @id))
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps WHERE deleted_at < @cutoff::timestamp;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.id, parent.reply_to_id, 1 AS depth
+    FROM chirps parent JOIN chirps child ON child.reply_to_id = parent.id
+    WHERE child.id = $1
+    UNION ALL
+    SELECT c.id, c.reply_to_id, a.depth + 1
+    FROM chirps c JOIN ancestors a ON c.id = a.reply_to_id
+)
+SELECT chirps.* FROM chirps JOIN ancestors ON chirps.id = ancestors.id
+WHERE chirps.deleted_at IS NULL
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpReplies :many
+WITH RECURSIVE descendants AS (
+    SELECT id FROM chirps WHERE reply_to_id = @chirp_id::uuid
+    UNION ALL
+    SELECT c.id FROM chirps c JOIN descendants d ON c.reply_to_id = d.id
+)
+SELECT chirps.* FROM chirps JOIN descendants ON chirps.id = descendants.id
+WHERE chirps.deleted_at IS NULL
+  AND (sqlc.narg(after_created_at)::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid))
+ORDER BY chirps.created_at, chirps.id
+LIMIT @max_results;
+
+-- name: GetChirpDescendantIDs :many
+WITH RECURSIVE descendants AS (
+    SELECT id FROM chirps WHERE reply_to_id = @chirp_id::uuid
+    UNION ALL
+    SELECT c.id FROM chirps c JOIN descendants d ON c.reply_to_id = d.id
+)
+SELECT id FROM descendants;
+
+-- name: CountRepliesForChirps :many
+SELECT reply_to_id, COUNT(*) AS reply_count FROM chirps
+WHERE reply_to_id = ANY(@chirp_ids::uuid[]) AND deleted_at IS NULL
+GROUP BY reply_to_id;
 
--- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1;
\ No newline at end of file
+-- name: GetTimeline :many
+SELECT * FROM chirps
+WHERE (user_id = @user_id::uuid
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = @user_id::uuid))
+  AND deleted_at IS NULL
+  AND (sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT @max_results;
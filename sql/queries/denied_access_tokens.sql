@@ -0,0 +1,29 @@
+-- name: DenyAccessToken :exec
+INSERT INTO denied_access_tokens (jti, created_at, expires_at)
+VALUES ($1, NOW(), $2)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: IsAccessTokenDenied :one
+SELECT EXISTS (
+    SELECT 1 FROM denied_access_tokens
+    WHERE jti = $1 AND expires_at > NOW()
+);
+
+-- name: DeleteExpiredDeniedAccessTokens :exec
+DELETE FROM denied_access_tokens WHERE expires_at <= NOW();
+
+-- name: DenyUserAccessTokens :exec
+INSERT INTO denied_users (user_id, denied_before, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET denied_before = GREATEST(denied_users.denied_before, EXCLUDED.denied_before),
+    expires_at = GREATEST(denied_users.expires_at, EXCLUDED.expires_at);
+
+-- name: IsUserAccessTokenDenied :one
+SELECT EXISTS (
+    SELECT 1 FROM denied_users
+    WHERE user_id = @user_id AND denied_before >= @issued_at::timestamp AND expires_at > NOW()
+);
+
+-- name: DeleteExpiredDeniedUsers :exec
+DELETE FROM denied_users WHERE expires_at <= NOW();
@@ -0,0 +1,29 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollowers :many
+SELECT * FROM follows
+WHERE followee_id = @user_id
+  AND (sqlc.narg(after_created_at)::timestamp IS NULL
+    OR (created_at, follower_id) > (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid))
+ORDER BY created_at, follower_id
+LIMIT @max_results;
+
+-- name: GetFollowing :many
+SELECT * FROM follows
+WHERE follower_id = @user_id
+  AND (sqlc.narg(after_created_at)::timestamp IS NULL
+    OR (created_at, followee_id) > (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid))
+ORDER BY created_at, followee_id
+LIMIT @max_results;
+
+-- name: GetFolloweeIDs :many
+SELECT followee_id FROM follows WHERE follower_id = $1;
+
+-- name: GetFollowsInvolvingUser :many
+SELECT * FROM follows WHERE follower_id = $1 OR followee_id = $1 ORDER BY created_at;
@@ -0,0 +1,21 @@
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, action, chirp_id, chirp_author_id, chirp_body)
+VALUES (
+    gen_random_uuid (),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetModerationActions :many
+SELECT * FROM moderation_actions ORDER BY created_at DESC;
+
+-- name: IsChirpDeletionModerated :one
+SELECT EXISTS (
+    SELECT 1 FROM moderation_actions
+    WHERE chirp_id = @chirp_id AND action = 'delete_chirp' AND created_at >= @deleted_at::timestamp
+);
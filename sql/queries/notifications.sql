@@ -0,0 +1,78 @@
+-- name: CreateNotifications :exec
+WITH created AS (
+    INSERT INTO notifications (id, created_at, user_id, actor_id, type, chirp_id)
+    SELECT gen_random_uuid(), NOW(), recipients.user_id, @actor_id::uuid, @type::text, sqlc.narg(chirp_id)::uuid
+    FROM unnest(@user_ids::uuid[]) AS recipients(user_id)
+    LEFT JOIN notification_preferences ON notification_preferences.user_id = recipients.user_id
+    WHERE recipients.user_id <> @actor_id::uuid
+      AND COALESCE(CASE @type::text
+          WHEN 'mention' THEN notification_preferences.mentions
+          WHEN 'reply' THEN notification_preferences.replies
+          WHEN 'like' THEN notification_preferences.likes
+          WHEN 'follow' THEN notification_preferences.follows
+        END, TRUE)
+      AND NOT EXISTS (
+        SELECT 1 FROM notifications AS existing
+        WHERE existing.user_id = recipients.user_id
+          AND existing.actor_id = @actor_id::uuid
+          AND existing.type = @type::text
+          AND existing.chirp_id IS NOT DISTINCT FROM sqlc.narg(chirp_id)::uuid
+      )
+    RETURNING notifications.*
+)
+SELECT pg_notify('notification_events', json_build_object(
+    'id', nextval('chirp_event_id_seq'),
+    'type', 'notification.created',
+    'author_id', created.actor_id,
+    'user_id', created.user_id,
+    'data', json_build_object(
+        'id', created.id,
+        'created_at', created.created_at,
+        'type', created.type,
+        'actor_id', created.actor_id,
+        'chirp_id', created.chirp_id,
+        'read', false
+    )
+)::text)
+FROM created;
+
+-- name: GetNotifications :many
+SELECT notifications.* FROM notifications
+LEFT JOIN chirps ON chirps.id = notifications.chirp_id
+WHERE notifications.user_id = @user_id
+  AND chirps.deleted_at IS NULL
+  AND (sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (notifications.created_at, notifications.id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid))
+ORDER BY notifications.created_at DESC, notifications.id DESC
+LIMIT @max_results;
+
+-- name: CountUnreadNotifications :one
+SELECT count(*) FROM notifications
+LEFT JOIN chirps ON chirps.id = notifications.chirp_id
+WHERE notifications.user_id = $1
+  AND notifications.read_at IS NULL
+  AND chirps.deleted_at IS NULL;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = @user_id
+  AND read_at IS NULL
+  AND (sqlc.narg(through_created_at)::timestamp IS NULL
+    OR (created_at, id) <= (sqlc.narg(through_created_at)::timestamp, sqlc.narg(through_id)::uuid));
+
+-- name: GetNotificationPreferences :one
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: UpsertNotificationPreferences :one
+INSERT INTO notification_preferences (user_id, updated_at, mentions, replies, likes, follows)
+VALUES ($1, NOW(), $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE SET
+    updated_at = NOW(),
+    mentions = EXCLUDED.mentions,
+    replies = EXCLUDED.replies,
+    likes = EXCLUDED.likes,
+    follows = EXCLUDED.follows
+RETURNING *;
+
+-- name: GetAllNotificationsForUser :many
+SELECT * FROM notifications WHERE user_id = $1 ORDER BY created_at, id;
@@ -0,0 +1,52 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, owner_id, name, secret_hash, redirect_uris, scopes)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients WHERE id = $1;
+
+-- name: GetOAuthClientsForUser :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients WHERE id = $1 AND owner_id = $2;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, created_at, client_id, user_id, redirect_uri, scope, code_challenge, expires_at)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6, $7);
+
+-- name: GetOAuthAuthorizationCodeForUpdate :one
+SELECT * FROM oauth_authorization_codes
+WHERE code_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+FOR UPDATE;
+
+-- name: ConsumeOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: CreateOAuthRefreshToken :one
+INSERT INTO oauth_refresh_tokens (token_hash, created_at, updated_at, client_id, user_id, family_id, scope, expires_at)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetOAuthRefreshToken :one
+SELECT * FROM oauth_refresh_tokens WHERE token_hash = $1;
+
+-- name: RevokeOAuthRefreshToken :execrows
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token_hash = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthRefreshTokenFamily :exec
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
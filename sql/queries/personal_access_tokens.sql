@@ -0,0 +1,33 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (id, created_at, updated_at, user_id, name, token_hash, token_prefix, scopes, expires_at)
+VALUES (
+    gen_random_uuid (),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetPersonalAccessTokenByHash :one
+SELECT personal_access_tokens.*, users.role FROM personal_access_tokens
+INNER JOIN users ON users.id = personal_access_tokens.user_id
+WHERE personal_access_tokens.token_hash = $1;
+
+-- name: GetPersonalAccessTokensForUser :many
+SELECT * FROM personal_access_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens
+SET last_used_at = NOW()
+WHERE id = $1
+AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
+
+-- name: DeletePersonalAccessToken :execrows
+DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2;
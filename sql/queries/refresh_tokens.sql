@@ -13,4 +13,9 @@ AND refresh_tokens.revoked_at IS NULL;
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens 
 SET revoked_at = $2, updated_at = $2
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: GetRefreshTokensForUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at;
\ No newline at end of file
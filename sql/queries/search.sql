@@ -0,0 +1,38 @@
+-- name: SearchChirps :many
+SELECT results.id, results.created_at, results.updated_at, results.body, results.user_id,
+    results.deleted_at, results.reply_to_id, results.repost_of_id, results.fanned_out_at,
+    results.rank,
+    ts_headline('english', results.body, websearch_to_tsquery('english', @query::text),
+        'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', MaxFragments=2')::text AS snippet
+FROM (
+    SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id,
+        chirps.deleted_at, chirps.reply_to_id, chirps.repost_of_id, chirps.fanned_out_at,
+        ts_rank(to_tsvector('english', chirps.body), websearch_to_tsquery('english', @query::text))::float8 AS rank
+    FROM chirps
+    WHERE to_tsvector('english', chirps.body) @@ websearch_to_tsquery('english', @query::text)
+      AND chirps.deleted_at IS NULL
+      AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id)::uuid)
+      AND (sqlc.narg(since)::timestamp IS NULL OR chirps.created_at >= sqlc.narg(since)::timestamp)
+      AND (sqlc.narg(until)::timestamp IS NULL OR chirps.created_at < sqlc.narg(until)::timestamp)
+) AS results
+WHERE sqlc.narg(after_rank)::float8 IS NULL
+   OR (results.rank, results.created_at, results.id)
+      < (sqlc.narg(after_rank)::float8, sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+ORDER BY results.rank DESC, results.created_at DESC, results.id DESC
+LIMIT @max_results;
+
+-- name: SearchUsers :many
+SELECT users.id, users.username, users.display_name, users.avatar_url,
+    GREATEST(similarity(lower(users.username), lower(@query::text)),
+        similarity(lower(users.display_name), lower(@query::text)))::float8 AS score
+FROM users
+WHERE lower(users.username) LIKE lower(@prefix::text) ESCAPE '\'
+   OR lower(users.display_name) LIKE lower(@prefix::text) ESCAPE '\'
+   OR lower(users.username) % lower(@query::text)
+   OR lower(users.display_name) % lower(@query::text)
+ORDER BY lower(users.username) = lower(@query::text) DESC,
+    lower(users.username) LIKE lower(@prefix::text) ESCAPE '\' DESC,
+    lower(users.display_name) LIKE lower(@prefix::text) ESCAPE '\' DESC,
+    score DESC,
+    users.username
+LIMIT @max_results;
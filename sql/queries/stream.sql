@@ -0,0 +1,8 @@
+-- name: NotifyChirpEvent :exec
+SELECT pg_notify('chirp_events', json_build_object(
+    'id', nextval('chirp_event_id_seq'),
+    'type', @type::text,
+    'author_id', @author_id::uuid,
+    'data', @data::json
+)::text);
+
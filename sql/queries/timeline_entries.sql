@@ -0,0 +1,84 @@
+-- name: FanOutChirp :execrows
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT follows.follower_id, chirps.id, chirps.user_id, chirps.created_at
+FROM chirps JOIN follows ON follows.followee_id = chirps.user_id
+WHERE chirps.id = $1
+UNION ALL
+SELECT chirps.user_id, chirps.id, chirps.user_id, chirps.created_at
+FROM chirps
+WHERE chirps.id = $1
+ON CONFLICT DO NOTHING;
+
+-- name: LockAuthorTimelines :exec
+SELECT pg_advisory_xact_lock(hashtextextended(@author_id::uuid::text, 0));
+
+-- name: MarkChirpFannedOut :exec
+UPDATE chirps SET fanned_out_at = NOW() WHERE id = $1;
+
+-- name: CountFollowers :one
+SELECT COUNT(*) FROM follows WHERE followee_id = $1;
+
+-- name: GetCachedTimeline :many
+SELECT chirps.* FROM (
+    (SELECT timeline_entries.chirp_id AS id
+    FROM timeline_entries JOIN chirps ON chirps.id = timeline_entries.chirp_id
+    WHERE timeline_entries.user_id = @user_id::uuid
+      AND chirps.deleted_at IS NULL
+      AND (sqlc.narg(before_created_at)::timestamp IS NULL
+        OR (timeline_entries.created_at, timeline_entries.chirp_id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid))
+    ORDER BY timeline_entries.created_at DESC, timeline_entries.chirp_id DESC
+    LIMIT @max_results)
+    UNION
+    (SELECT chirps.id
+    FROM chirps
+    WHERE chirps.fanned_out_at IS NULL
+      AND chirps.deleted_at IS NULL
+      AND (chirps.user_id = @user_id::uuid
+        OR chirps.user_id IN (SELECT followee_id FROM follows WHERE follower_id = @user_id::uuid))
+      AND (sqlc.narg(before_created_at)::timestamp IS NULL
+        OR (chirps.created_at, chirps.id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid))
+    ORDER BY chirps.created_at DESC, chirps.id DESC
+    LIMIT @max_results)
+) AS page JOIN chirps ON chirps.id = page.id
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT @max_results;
+
+-- name: BackfillTimelineFromAuthor :exec
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT @user_id::uuid, id, user_id, created_at
+FROM chirps
+WHERE user_id = @author_id::uuid AND fanned_out_at IS NOT NULL AND deleted_at IS NULL
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteTimelineEntriesFromAuthor :exec
+DELETE FROM timeline_entries WHERE user_id = $1 AND author_id = $2;
+
+-- name: DeleteTimelineEntriesForUser :exec
+DELETE FROM timeline_entries WHERE user_id = $1;
+
+-- name: RebuildTimelineForUser :execrows
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT @user_id::uuid, id, user_id, created_at
+FROM chirps
+WHERE fanned_out_at IS NOT NULL
+  AND deleted_at IS NULL
+  AND (user_id = @user_id::uuid
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = @user_id::uuid))
+ON CONFLICT DO NOTHING;
+
+-- name: CountMissingTimelineEntries :one
+SELECT COUNT(*) FROM chirps
+WHERE fanned_out_at IS NOT NULL
+  AND deleted_at IS NULL
+  AND (user_id = @user_id::uuid
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = @user_id::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM timeline_entries
+    WHERE timeline_entries.user_id = @user_id::uuid AND timeline_entries.chirp_id = chirps.id
+  );
+
+-- name: CountStaleTimelineEntries :one
+SELECT COUNT(*) FROM timeline_entries
+WHERE user_id = @user_id::uuid
+  AND author_id <> @user_id::uuid
+  AND author_id NOT IN (SELECT followee_id FROM follows WHERE follower_id = @user_id::uuid);
@@ -0,0 +1,34 @@
+-- name: GetHashtagWindowStats :many
+SELECT chirp_hashtags.tag,
+    COUNT(*) FILTER (WHERE chirp_hashtags.created_at >= @window_start::timestamp) AS uses,
+    COALESCE(SUM(power(0.5, EXTRACT(EPOCH FROM (@now::timestamp - chirp_hashtags.created_at)) / @half_life_seconds::float8))
+        FILTER (WHERE chirp_hashtags.created_at >= @window_start::timestamp), 0)::float8 AS decayed,
+    COUNT(*) FILTER (WHERE chirp_hashtags.created_at < @window_start::timestamp) AS baseline_uses
+FROM chirp_hashtags
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirp_hashtags.created_at >= @baseline_start::timestamp
+  AND chirps.deleted_at IS NULL
+GROUP BY chirp_hashtags.tag
+HAVING COUNT(*) FILTER (WHERE chirp_hashtags.created_at >= @window_start::timestamp) >= @min_uses::bigint;
+
+-- name: CreateTrendComputation :exec
+INSERT INTO trend_computations (window_name, computed_at)
+VALUES ($1, $2);
+
+-- name: CreateTrendSnapshot :exec
+INSERT INTO trend_snapshots (window_name, computed_at, rank, tag, score, uses)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetLatestTrendComputation :one
+SELECT computed_at FROM trend_computations
+WHERE window_name = $1
+ORDER BY computed_at DESC
+LIMIT 1;
+
+-- name: GetTrendSnapshot :many
+SELECT * FROM trend_snapshots
+WHERE window_name = $1 AND computed_at = $2
+ORDER BY rank;
+
+-- name: DeleteTrendComputationsBefore :exec
+DELETE FROM trend_computations WHERE computed_at < $1;
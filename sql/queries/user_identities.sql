@@ -0,0 +1,20 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, created_at, updated_at, user_id, issuer, subject, email)
+VALUES (
+    gen_random_uuid (),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetUserByIdentity :one
+SELECT users.* FROM users
+INNER JOIN user_identities ON users.id = user_identities.user_id
+WHERE user_identities.issuer = $1 AND user_identities.subject = $2;
+
+-- name: GetUserIdentitiesForUser :many
+SELECT * FROM user_identities WHERE user_id = $1 ORDER BY created_at;
@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username)
 VALUES (
     gen_random_uuid (),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -18,4 +19,34 @@ SELECT * FROM users WHERE email = $1;
 -- name: UpdateUser :exec
 UPDATE users
 SET email = $1, hashed_password= $2
-WHERE id = $3;
\ No newline at end of file
+WHERE id = $3;
+
+-- name: GetUser :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: SetUserRole :exec
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users WHERE lower(username) = lower(@username::text);
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET username = COALESCE(sqlc.narg(username), username),
+    display_name = COALESCE(sqlc.narg(display_name), display_name),
+    bio = COALESCE(sqlc.narg(bio), bio),
+    avatar_url = COALESCE(sqlc.narg(avatar_url), avatar_url),
+    updated_at = NOW()
+WHERE id = @id
+RETURNING *;
+
+-- name: GetAuthorsByIDs :many
+SELECT id, username, display_name FROM users WHERE id = ANY(@ids::uuid[]);
+
+-- name: GetUsersByUsernames :many
+SELECT id, username FROM users WHERE lower(username) = ANY(@usernames::text[]);
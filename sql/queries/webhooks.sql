@@ -0,0 +1,67 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, events, all_users)
+VALUES (
+    gen_random_uuid (),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetWebhooksForUser :many
+SELECT * FROM webhooks WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetWebhookForUser :one
+SELECT * FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event, payload, status, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), webhooks.id, @event::text, @payload::jsonb, 'pending', NOW()
+FROM webhooks
+WHERE @event::text = ANY(webhooks.events)
+  AND (webhooks.all_users OR webhooks.user_id = @user_id::uuid);
+
+-- name: ClaimWebhookDeliveries :many
+WITH due AS (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT @max_results
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + make_interval(secs => @lease_seconds::float8)
+FROM due, webhooks
+WHERE webhook_deliveries.id = due.id AND webhooks.id = webhook_deliveries.webhook_id
+RETURNING webhook_deliveries.id, webhook_deliveries.event, webhook_deliveries.payload,
+    webhook_deliveries.attempts, webhooks.url, webhooks.secret, webhooks.all_users;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = @status,
+    attempts = @attempts,
+    next_attempt_at = @next_attempt_at,
+    last_attempt_at = NOW(),
+    response_status = sqlc.narg(response_status),
+    last_error = sqlc.narg(last_error)
+WHERE id = @id;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = @webhook_id
+  AND (sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT @max_results;
+
+-- name: RetryWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+WHERE id = $1 AND webhook_id = $2 AND status = 'dead';
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    chirp_id UUID NOT NULL,
+    chirp_author_id UUID NOT NULL,
+    chirp_body TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,30 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN repost_of_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_repost_of_id_idx ON chirps (repost_of_id);
+
+-- A plain rechirp has an empty body; quote chirps carry their own text and
+-- are not limited.
+CREATE UNIQUE INDEX chirps_one_rechirp_idx ON chirps (user_id, repost_of_id)
+    WHERE body = '' AND deleted_at IS NULL;
+
+-- A plain rechirp is nothing without its original, so it goes with it. Quote
+-- chirps keep their text and only lose the link.
+-- +goose StatementBegin
+CREATE FUNCTION delete_plain_rechirps() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM chirps WHERE repost_of_id = OLD.id AND body = '';
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_delete_plain_rechirps BEFORE DELETE ON chirps
+    FOR EACH ROW EXECUTE FUNCTION delete_plain_rechirps();
+
+-- +goose Down
+DROP TRIGGER chirps_delete_plain_rechirps ON chirps;
+DROP FUNCTION delete_plain_rechirps;
+DROP INDEX chirps_one_rechirp_idx;
+DROP INDEX chirps_repost_of_id_idx;
+ALTER TABLE chirps DROP COLUMN repost_of_id;
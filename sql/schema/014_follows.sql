@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+-- The primary key serves "who does X follow"; this serves "who follows X".
+CREATE INDEX follows_followee_id_idx ON follows (followee_id, created_at);
+
+-- Timeline reads walk each author's newest live chirps.
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at DESC, id DESC)
+    WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+DROP TABLE follows;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Set once a chirp has been copied into its followers' timelines. Chirps
+-- without it (authors above the fan-out threshold, or not yet processed) are
+-- merged into timelines at read time instead.
+ALTER TABLE chirps ADD COLUMN fanned_out_at TIMESTAMP;
+
+CREATE INDEX chirps_pending_fanout_idx ON chirps (user_id, created_at DESC, id DESC)
+    WHERE deleted_at IS NULL AND fanned_out_at IS NULL;
+
+CREATE TABLE timeline_entries (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX timeline_entries_page_idx ON timeline_entries (user_id, created_at DESC, chirp_id DESC);
+CREATE INDEX timeline_entries_author_idx ON timeline_entries (user_id, author_id);
+
+-- +goose Down
+DROP TABLE timeline_entries;
+DROP INDEX chirps_pending_fanout_idx;
+ALTER TABLE chirps DROP COLUMN fanned_out_at;
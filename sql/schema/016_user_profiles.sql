@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN username TEXT,
+    ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+    ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+
+-- Existing accounts get a placeholder handle they can change later.
+UPDATE users SET username = 'user_' || substr(replace(id::text, '-', ''), 1, 10);
+
+ALTER TABLE users ALTER COLUMN username SET NOT NULL;
+
+-- Handles are unique regardless of case.
+CREATE UNIQUE INDEX users_username_idx ON users (lower(username));
+
+-- +goose Down
+DROP INDEX users_username_idx;
+ALTER TABLE users
+    DROP COLUMN avatar_url,
+    DROP COLUMN bio,
+    DROP COLUMN display_name,
+    DROP COLUMN username;
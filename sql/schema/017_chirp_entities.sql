@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE chirp_mentions (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    handle TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions (user_id, created_at DESC, chirp_id DESC);
+
+CREATE TABLE chirp_hashtags (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, tag)
+);
+
+CREATE INDEX chirp_hashtags_tag_idx ON chirp_hashtags (tag, created_at DESC, chirp_id DESC);
+
+-- +goose Down
+DROP TABLE chirp_hashtags;
+DROP TABLE chirp_mentions;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE INDEX chirp_hashtags_created_at_idx ON chirp_hashtags (created_at);
+
+-- Every computation is recorded, even one that found nothing trending, so
+-- the latest one always says what is trending now.
+CREATE TABLE trend_computations (
+    window_name TEXT NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (window_name, computed_at)
+);
+
+CREATE TABLE trend_snapshots (
+    window_name TEXT NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    rank INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    uses BIGINT NOT NULL,
+    PRIMARY KEY (window_name, computed_at, rank),
+    FOREIGN KEY (window_name, computed_at)
+        REFERENCES trend_computations (window_name, computed_at) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE trend_snapshots;
+DROP TABLE trend_computations;
+DROP INDEX chirp_hashtags_created_at_idx;
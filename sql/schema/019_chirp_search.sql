@@ -0,0 +1,9 @@
+-- +goose Up
+-- Search matches against to_tsvector('english', body). It is indexed as an
+-- expression rather than stored in a generated search_vector column: a
+-- stored column would be read by every SELECT * on chirps for no use, while
+-- the index is only touched by the search query.
+CREATE INDEX chirps_body_search_idx ON chirps USING GIN (to_tsvector('english', body));
+
+-- +goose Down
+DROP INDEX chirps_body_search_idx;
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    read_at TIMESTAMP
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at DESC, id DESC);
+CREATE INDEX notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+
+CREATE TABLE notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    updated_at TIMESTAMP NOT NULL,
+    mentions BOOLEAN NOT NULL DEFAULT TRUE,
+    replies BOOLEAN NOT NULL DEFAULT TRUE,
+    likes BOOLEAN NOT NULL DEFAULT TRUE,
+    follows BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
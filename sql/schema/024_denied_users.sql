@@ -0,0 +1,15 @@
+-- +goose Up
+-- Rejects every access token issued to a user before denied_before, for when
+-- a single jti is not enough, such as account deletion. Like
+-- denied_access_tokens, rows are only kept until those tokens would have
+-- expired anyway. There is no foreign key: the user is usually gone.
+CREATE TABLE denied_users (
+    user_id UUID PRIMARY KEY,
+    denied_before TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX denied_users_expires_at_idx ON denied_users (expires_at);
+
+-- +goose Down
+DROP TABLE denied_users;